@@ -17,9 +17,11 @@ var Analyzer = &analysis.Analyzer{
 	},
 }
 
+var query = astquery.MustCompile("//*[@type='CallExpr']/Fun[@type='Ident' and @Name='panic']")
+
 func run(pass *analysis.Pass) (interface{}, error) {
 	e := pass.ResultOf[astquery.Analyzer].(*astquery.Evaluator)
-	ns, err := e.Select("//*[@type='CallExpr']/Fun[@type='Ident' and @Name='panic']")
+	ns, err := e.SelectQuery(query)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,8 @@
+package main
+
+import (
+	"github.com/gostaticanalysis/astquery/_example/analyzers/errorsnew"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() { unitchecker.Main(errorsnew.Analyzer) }
@@ -0,0 +1,37 @@
+package errorsnew
+
+import (
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/analysis"
+)
+
+const doc = "errorsnew finds fmt.Errorf calls without formatting and suggests errors.New"
+
+// Analyzer is ...
+var Analyzer = &analysis.Analyzer{
+	Name: "errorsnew",
+	Doc:  doc,
+	Run:  run,
+	Requires: []*analysis.Analyzer{
+		astquery.Analyzer,
+	},
+}
+
+var (
+	query    = astquery.MustCompile("//*[@type='CallExpr'][Fun/X/@Name='fmt' and Fun/Sel/@Name='Errorf' and count(Args)=1]")
+	template = astquery.MustParseTemplate("errors.New({{.Args[0]}})")
+)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	e := pass.ResultOf[astquery.Analyzer].(*astquery.Evaluator)
+	ds, err := e.Diagnostics(query, "use errors.New", template)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range ds {
+		pass.Report(d)
+	}
+
+	return nil, nil
+}
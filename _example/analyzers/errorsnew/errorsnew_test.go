@@ -0,0 +1,14 @@
+package errorsnew_test
+
+import (
+	"testing"
+
+	"github.com/gostaticanalysis/astquery/_example/analyzers/errorsnew"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+// TestAnalyzer is a test for Analyzer.
+func TestAnalyzer(t *testing.T) {
+	testdata := analysistest.TestData()
+	analysistest.RunWithSuggestedFixes(t, testdata, errorsnew.Analyzer, "a")
+}
@@ -0,0 +1,13 @@
+package a
+
+import (
+	"errors"
+	"fmt"
+)
+
+func f() error {
+	_ = fmt.Errorf("%d", 10)
+	return fmt.Errorf("error") // want "use errors.New"
+}
+
+var _ = errors.New
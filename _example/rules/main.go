@@ -0,0 +1,34 @@
+// rules is a linter which checks rules in a JSON file.
+// The file is specified by ASTQUERY_RULES environment variable (default: astquery.json).
+//
+//	$ ASTQUERY_RULES=rules.json go run ./_example/rules ./...
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	path := os.Getenv("ASTQUERY_RULES")
+	if path == "" {
+		path = "astquery.json"
+	}
+
+	rules, err := astquery.LoadRulesFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	a, err := astquery.NewRuleAnalyzer("rules", rules)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	singlechecker.Main(a)
+}
@@ -38,5 +38,5 @@ type analyzer struct{}
 
 func (analyzer) run(pass *analysis.Pass) (interface{}, error) {
 	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
-	return New(pass.Fset, pass.Files, inspect), nil
+	return New(pass.Fset, pass.Files, inspect, WithTypesInfo(pass.Pkg, pass.TypesInfo)), nil
 }
@@ -0,0 +1,104 @@
+package astquery
+
+import (
+	"fmt"
+	"go/ast"
+	"strconv"
+)
+
+// Capture is a node which is selected by Evaluator.Capture with the results of the capture expressions.
+type Capture struct {
+	Node   ast.Node
+	Nodes  map[string][]ast.Node // element nodes of a node set
+	Values map[string]string     // the first attribute value of a node set, or a string, a number or a bool
+}
+
+// Capture selects nodes by the XPath expression and evaluates the capture expressions
+// which are relative to each selected node such as "Fun/X" and "Fun/Sel/@Name".
+// The results are stored in the Capture with the names of the captures.
+// A capture which selects nothing is not stored.
+// Attributes selected by the expression are ignored.
+//
+// Example:
+//	cs, err := e.Capture(`//*[@type="CallExpr"][Fun/@type="SelectorExpr"]`, map[string]string{
+//		"recv":   "Fun/X",
+//		"method": "Fun/Sel/@Name",
+//	})
+//	recv, method := cs[0].Nodes["recv"][0], cs[0].Values["method"]
+func (e *Evaluator) Capture(expr string, captures map[string]string) ([]*Capture, error) {
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	qs := make(map[string]*Query, len(captures))
+	for name, c := range captures {
+		qs[name], err = Compile(c)
+		if err != nil {
+			return nil, fmt.Errorf("capture %s: %w", name, err)
+		}
+	}
+
+	return e.CaptureQuery(q, qs)
+}
+
+// CaptureQuery is like Capture but takes compiled queries.
+// The queries can be shared by goroutines which call CaptureQuery concurrently.
+func (e *Evaluator) CaptureQuery(q *Query, captures map[string]*Query) ([]*Capture, error) {
+	ns, err := e.SelectQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := make([]*Capture, len(ns))
+	for i, n := range ns {
+		c := &Capture{
+			Node:   n,
+			Nodes:  make(map[string][]ast.Node),
+			Values: make(map[string]string),
+		}
+
+		for name, q := range captures {
+			r, err := e.evaluateFrom(n, q)
+			if err != nil {
+				return nil, fmt.Errorf("capture %s: %w", name, err)
+			}
+			c.set(name, r)
+		}
+
+		cs[i] = c
+	}
+
+	return cs, nil
+}
+
+// evaluateFrom evaluates the query with the node as the context node.
+func (e *Evaluator) evaluateFrom(node ast.Node, q *Query) (_ *Result, rerr error) {
+	defer recoverEval(&rerr)
+	n, err := e.navigator(q)
+	if err != nil {
+		return nil, err
+	}
+	if !n.moveToNode(node) {
+		return nil, fmt.Errorf("node %T is not in the tree", node)
+	}
+	return newResult(e.n.fset, n.query.expr.Evaluate(n)), nil
+}
+
+func (c *Capture) set(name string, r *Result) {
+	switch r.Kind() {
+	case KindBool:
+		c.Values[name] = strconv.FormatBool(r.Bool())
+	case KindNumber:
+		c.Values[name] = strconv.FormatFloat(r.Number(), 'f', -1, 64)
+	case KindString:
+		c.Values[name] = r.String()
+	case KindNodeSet:
+		if ns := r.Nodes(); len(ns) > 0 {
+			c.Nodes[name] = ns
+		}
+		if attrs := r.Attributes(); len(attrs) > 0 {
+			c.Values[name] = attrs[0].Value
+		}
+	}
+}
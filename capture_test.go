@@ -0,0 +1,136 @@
+package astquery_test
+
+import (
+	"go/ast"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestEvaluator_Capture(t *testing.T) {
+	t.Parallel()
+
+	M := func(kvs ...string) map[string]string {
+		m := make(map[string]string)
+		for i := 0; i < len(kvs); i += 2 {
+			m[kvs[i]] = kvs[i+1]
+		}
+		return m
+	}
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Capture", f) }
+	cases := map[string]struct {
+		path     string
+		expr     string
+		captures map[string]string
+		want     []string
+		wantErr  bool
+	}{
+		"selector": {TD("a.go"), "//*[@type='CallExpr'][Fun/@type='SelectorExpr']", M("recv", "Fun/X", "method", "Fun/Sel/@Name"), []string{`t.M(1) method="M" recv=[t]`, `strings.ToUpper(s) method="ToUpper" recv=[strings]`}, false},
+		"list":     {TD("a.go"), "//*[@type='FuncDecl'][Name/@Name='f']", M("params", "Type/Params/List/Names"), []string{"func f params=[t s]"}, false},
+		"values":   {TD("a.go"), "//*[@type='CallExpr']", M("n", "count(Args)", "fun", "string(Fun/@src)", "sel", "boolean(Fun/Sel)"), []string{`t.M(1) fun="t.M" n="1" sel="true"`, `strings.ToUpper(s) fun="strings.ToUpper" n="1" sel="true"`, `println(s) fun="println" n="1" sel="false"`}, false},
+		"nothing":  {TD("a.go"), "//*[@type='CallExpr'][Fun/@Name='println']", M("recv", "Fun/X"), []string{"println(s)"}, false},
+		"expr":     {TD("a.go"), "//*[", nil, nil, true},
+		"capture":  {TD("a.go"), "//*", M("x", "Fun/["), nil, true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			cs, err := e.Capture(tt.expr, tt.captures)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			var got []string
+			for _, c := range cs {
+				got = append(got, captureString(e, c))
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEvaluator_CaptureQuery_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	e := newEvaluator(t, filepath.Join("testdata", "TestEvaluator_Capture", "a.go"))
+	q := astquery.MustCompile("//*[@type='CallExpr']")
+	captures := map[string]*astquery.Query{
+		"fun": astquery.MustCompile("Fun"),
+		"n":   astquery.MustCompile("count(Args)"),
+		"sel": astquery.MustCompile("string(Fun/Sel/@Name)"),
+	}
+
+	want, err := e.CaptureQuery(q, captures)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	// the compiled queries are shared by the goroutines
+	const n = 8
+	results := make([][]*astquery.Capture, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = e.CaptureQuery(q, captures)
+		}()
+	}
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatal("unexpected error:", errs[i])
+		}
+		if diff := cmp.Diff(want, results[i]); diff != "" {
+			t.Error(diff)
+		}
+	}
+}
+
+func captureString(e *astquery.Evaluator, c *astquery.Capture) string {
+	attr := func(n ast.Node, name string) string {
+		v, _ := e.Attribute(n, name)
+		return v
+	}
+	src := func(n ast.Node) string {
+		if n, ok := n.(*ast.FuncDecl); ok {
+			return "func " + n.Name.Name
+		}
+		return attr(n, "src")
+	}
+
+	var cs []string
+	for name, ns := range c.Nodes {
+		ss := make([]string, len(ns))
+		for i := range ns {
+			ss[i] = src(ns[i])
+		}
+		cs = append(cs, name+"=["+strings.Join(ss, " ")+"]")
+	}
+	for name, v := range c.Values {
+		cs = append(cs, name+"="+strconv.Quote(v))
+	}
+	sort.Strings(cs)
+
+	return strings.Join(append([]string{src(c.Node)}, cs...), " ")
+}
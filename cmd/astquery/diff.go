@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of context lines of a hunk.
+const diffContext = 3
+
+// diffOp is an operation of an edit script: ' ' keeps, '-' deletes and '+' inserts a line.
+type diffOp struct {
+	kind byte
+	line string
+}
+
+// diff returns a unified diff between the original and the rewritten source code.
+// It returns nil if they are same.
+func diff(b1, b2 []byte, filename string) []byte {
+	ops := diffLines(splitLines(b1), splitLines(b2))
+
+	var changes []int // indexes of changed operations
+	for i, op := range ops {
+		if op.kind != ' ' {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	// line numbers in the original and the rewritten source before each operation
+	lines1, lines2 := make([]int, len(ops)+1), make([]int, len(ops)+1)
+	for i, op := range ops {
+		lines1[i+1], lines2[i+1] = lines1[i], lines2[i]
+		if op.kind != '+' {
+			lines1[i+1]++
+		}
+		if op.kind != '-' {
+			lines2[i+1]++
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s.orig\n+++ %s\n", filename, filename)
+	for i := 0; i < len(changes); {
+		// changes which are close to each other are in the same hunk
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*diffContext {
+			j++
+		}
+
+		start, end := changes[i]-diffContext, changes[j]+diffContext+1
+		if start < 0 {
+			start = 0
+		}
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		fmt.Fprintf(&buf, "@@ -%s +%s @@\n",
+			hunkRange(lines1[start], lines1[end]-lines1[start]),
+			hunkRange(lines2[start], lines2[end]-lines2[start]))
+		for _, op := range ops[start:end] {
+			fmt.Fprintf(&buf, "%c%s\n", op.kind, op.line)
+		}
+
+		i = j + 1
+	}
+
+	return buf.Bytes()
+}
+
+// hunkRange returns a range of a hunk header such as "3,7" like diff -u.
+// An empty range starts at the line before the hunk.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(b []byte) []string {
+	s := strings.TrimSuffix(string(b), "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines returns the shortest edit script from a to b by Myers' algorithm.
+func diffLines(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	max := n + m
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+loop:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+				x = v[max+k+1]
+			} else {
+				x = v[max+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[max+k] = x
+			if x >= n && y >= m {
+				break loop
+			}
+		}
+	}
+
+	// backtrack from the end
+	var ops []diffOp
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v[max+k-1] < v[max+k+1] {
+			prevK = k + 1
+		}
+		prevX := v[max+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			ops = append(ops, diffOp{' ', a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				ops = append(ops, diffOp{'+', b[y-1]})
+				y--
+			} else {
+				ops = append(ops, diffOp{'-', a[x-1]})
+				x--
+			}
+		}
+	}
+
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+
+	return ops
+}
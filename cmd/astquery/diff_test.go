@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		b1, b2 string
+		want   string
+	}{
+		"same":   {"a\nb\n", "a\nb\n", ""},
+		"change": {"a\nb\nc\n", "a\nB\nc\n", "--- a.go.orig\n+++ a.go\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		"hunks": {
+			"a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n",
+			"a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\nm\n",
+			"--- a.go.orig\n+++ a.go\n@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n@@ -9,4 +9,5 @@\n i\n j\n k\n-l\n+L\n+m\n",
+		},
+		"merged": {"a\nb\nc\nd\ne\n", "A\nb\nc\nd\nE\n", "--- a.go.orig\n+++ a.go\n@@ -1,5 +1,5 @@\n-a\n+A\n b\n c\n d\n-e\n+E\n"},
+		"insert": {"", "a\n", "--- a.go.orig\n+++ a.go\n@@ -0,0 +1 @@\n+a\n"},
+		"delete": {"a\nb\n", "", "--- a.go.orig\n+++ a.go\n@@ -1,2 +0,0 @@\n-a\n-b\n"},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			got := string(diff([]byte(tt.b1), []byte(tt.b2), "a.go"))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gostaticanalysis/astquery"
+)
+
+// printResult prints the result in the format which is specified by -format flag.
+// If m is not nil, messages for the nodes are printed.
+func printResult(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, expr string, r *astquery.Result, m *astquery.Message) error {
+	switch flagFormat {
+	case "text":
+		return printText(w, e, fset, r, m)
+	case "json", "jsonl":
+		return printJSON(w, e, fset, r, m, flagFormat == "jsonl")
+	case "sarif":
+		return printSARIF(w, e, fset, expr)
+	case "xml":
+		return printXML(w, e, r)
+	default:
+		return fmt.Errorf("unknown format %q", flagFormat)
+	}
+}
+
+func printText(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, r *astquery.Result, m *astquery.Message) error {
+	if m != nil {
+		return printMessages(w, e, fset, r, m)
+	}
+
+	switch r.Kind() {
+	case astquery.KindBool:
+		fmt.Fprintln(w, r.Bool())
+	case astquery.KindNumber:
+		fmt.Fprintln(w, r.Number())
+	case astquery.KindString:
+		fmt.Fprintln(w, r.String())
+	case astquery.KindNodeSet:
+		if err := newGrepPrinter(w, fset).print(r.Nodes()); err != nil {
+			return err
+		}
+		for _, a := range r.Attributes() {
+			if flagPos {
+				fmt.Fprintf(w, "%v: %s=%s\n", a.Position, a.Name, a.Value)
+			} else {
+				fmt.Fprintln(w, a.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// printMessages prints messages for the nodes and the owners of the attributes in the result.
+func printMessages(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, r *astquery.Result, m *astquery.Message) error {
+	ns := r.Nodes()
+	for _, a := range r.Attributes() {
+		ns = append(ns, a.Node)
+	}
+
+	for _, n := range ns {
+		s, err := e.Message(m, n)
+		if err != nil {
+			return err
+		}
+
+		if flagPos {
+			fmt.Fprintf(w, "%v: %s\n", fset.Position(n.Pos()), s)
+		} else {
+			fmt.Fprintln(w, s)
+		}
+	}
+
+	return nil
+}
+
+// record is a JSON representation of a selected node or attribute.
+// If the result is not a node set, only Value is set.
+type record struct {
+	Type     string      `json:"type,omitempty"`
+	File     string      `json:"file,omitempty"`
+	Line     int         `json:"line,omitempty"`
+	Col      int         `json:"col,omitempty"`
+	EndLine  int         `json:"endline,omitempty"`
+	EndCol   int         `json:"endcol,omitempty"`
+	Src      string      `json:"src,omitempty"`
+	Name     string      `json:"name,omitempty"` // name of a selected attribute
+	Value    interface{} `json:"value,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Rule     string      `json:"rule,omitempty"`     // ID of a rule which reports the node
+	Severity string      `json:"severity,omitempty"` // severity of the rule
+
+	Bindings map[string]string `json:"bindings,omitempty"` // sources of nodes bound to metavariables of a pattern or captured values
+}
+
+func printJSON(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, r *astquery.Result, m *astquery.Message, lines bool) error {
+	var rs []*record
+	switch r.Kind() {
+	case astquery.KindBool:
+		rs = append(rs, &record{Value: r.Bool()})
+	case astquery.KindNumber:
+		rs = append(rs, &record{Value: r.Number()})
+	case astquery.KindString:
+		rs = append(rs, &record{Value: r.String()})
+	case astquery.KindNodeSet:
+		for _, n := range r.Nodes() {
+			rec, err := newRecord(e, fset, n, m)
+			if err != nil {
+				return err
+			}
+			rs = append(rs, rec)
+		}
+		for _, a := range r.Attributes() {
+			rec, err := newRecord(e, fset, a.Node, m)
+			if err != nil {
+				return err
+			}
+			rec.Name, rec.Value = a.Name, a.Value
+			rs = append(rs, rec)
+		}
+	}
+
+	return writeJSON(w, rs, lines)
+}
+
+func writeJSON(w io.Writer, rs []*record, lines bool) error {
+	enc := json.NewEncoder(w)
+	if !lines {
+		enc.SetIndent("", "\t")
+		if rs == nil {
+			rs = []*record{}
+		}
+		return enc.Encode(rs)
+	}
+
+	for _, rec := range rs {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newRecord(e *astquery.Evaluator, fset *token.FileSet, n ast.Node, m *astquery.Message) (*record, error) {
+	rec := &record{}
+	rec.Type, _ = e.Attribute(n, "type")
+	rec.Src, _ = e.Attribute(n, "src")
+
+	if n.Pos().IsValid() {
+		pos, end := fset.Position(n.Pos()), fset.Position(n.End())
+		rec.File, rec.Line, rec.Col = pos.Filename, pos.Line, pos.Column
+		rec.EndLine, rec.EndCol = end.Line, end.Column
+	}
+
+	if m != nil {
+		msg, err := e.Message(m, n)
+		if err != nil {
+			return nil, err
+		}
+		rec.Message = msg
+	}
+
+	return rec, nil
+}
+
+// sarifMessage is the message of SARIF results if -msg flag is not specified.
+// The expression is not used as the message because it is parsed as a template.
+const sarifMessage = "matched astquery expression"
+
+// printSARIF prints selected nodes and owners of selected attributes as results of a rule
+// whose ID is "astquery" and XPath is the expression.
+func printSARIF(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, expr string) error {
+	msg := flagMsg
+	if msg == "" {
+		msg = sarifMessage
+	}
+	rule := &astquery.Rule{ID: "astquery", XPath: expr, Message: msg}
+	findings, err := e.CheckRules([]*astquery.Rule{rule})
+	if err != nil {
+		return err
+	}
+
+	return printFindings(w, e, fset, []*astquery.Rule{rule}, findings)
+}
+
+// printFindings prints findings of the rules in the format which is specified by -format flag.
+func printFindings(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, rules []*astquery.Rule, findings []*astquery.Finding) error {
+	switch flagFormat {
+	case "text":
+		for _, f := range findings {
+			fmt.Fprintf(w, "%v: %s: %s (%s)\n", fset.Position(f.Diagnostic.Pos), f.Rule.EffectiveSeverity(), f.Diagnostic.Message, f.Rule.ID)
+		}
+		return nil
+	case "json", "jsonl":
+		rs := make([]*record, len(findings))
+		for i, f := range findings {
+			rec, err := newRecord(e, fset, f.Node, nil)
+			if err != nil {
+				return err
+			}
+			rec.Message, rec.Rule, rec.Severity = f.Diagnostic.Message, f.Rule.ID, string(f.Rule.EffectiveSeverity())
+			rs[i] = rec
+		}
+		return writeJSON(w, rs, flagFormat == "jsonl")
+	case "sarif":
+		reporter := astquery.NewSARIFReporter(rules)
+		if wd, err := os.Getwd(); err == nil {
+			reporter.BaseDir = wd
+		}
+		for _, f := range findings {
+			reporter.Report(fset, f)
+		}
+		return reporter.Write(w)
+	default:
+		return fmt.Errorf("unknown format %q", flagFormat)
+	}
+}
+
+// matched is a node which matches a pattern or is selected with captures.
+type matched struct {
+	node     ast.Node
+	bindings map[string]string // sources of nodes bound to metavariables or captured values
+}
+
+// printMatches prints nodes which match a pattern or are selected with captures and their bindings.
+func printMatches(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, ms []*matched, m *astquery.Message) error {
+	switch flagFormat {
+	case "text":
+		p := newGrepPrinter(w, fset)
+		for _, match := range ms {
+			if m != nil {
+				s, err := e.Message(m, match.node)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(w, "%v: %s\n", fset.Position(match.node.Pos()), s)
+			} else if err := p.print([]ast.Node{match.node}); err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(match.bindings))
+			for name := range match.bindings {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(w, "\t%s = %s\n", name, match.bindings[name])
+			}
+		}
+		return nil
+	case "json", "jsonl":
+		rs := make([]*record, len(ms))
+		for i, match := range ms {
+			rec, err := newRecord(e, fset, match.node, m)
+			if err != nil {
+				return err
+			}
+			rec.Bindings = match.bindings
+			rs[i] = rec
+		}
+		return writeJSON(w, rs, flagFormat == "jsonl")
+	default:
+		return fmt.Errorf("format %q is not supported for patterns and captures", flagFormat)
+	}
+}
+
+// bindings returns sources of nodes bound to metavariables such as "$x" and "$*xs".
+func bindings(e *astquery.Evaluator, m *astquery.Match) map[string]string {
+	bs := make(map[string]string, len(m.Nodes)+len(m.Lists))
+	for name, n := range m.Nodes {
+		bs["$"+name], _ = e.Attribute(n, "src")
+	}
+	for name, ns := range m.Lists {
+		bs["$*"+name] = sources(e, ns)
+	}
+	return bs
+}
+
+// captures returns captured values and sources of captured nodes.
+func captures(e *astquery.Evaluator, c *astquery.Capture) map[string]string {
+	cs := make(map[string]string, len(c.Nodes)+len(c.Values))
+	for name, ns := range c.Nodes {
+		cs[name] = sources(e, ns)
+	}
+	for name, v := range c.Values {
+		cs[name] = v
+	}
+	return cs
+}
+
+// sources returns sources of the nodes separated by commas.
+func sources(e *astquery.Evaluator, ns []ast.Node) string {
+	srcs := make([]string, len(ns))
+	for i := range ns {
+		srcs[i], _ = e.Attribute(ns[i], "src")
+	}
+	return strings.Join(srcs, ", ")
+}
+
+// printXML prints trees under the selected nodes as XML.
+func printXML(w io.Writer, e *astquery.Evaluator, r *astquery.Result) error {
+	if r.Kind() != astquery.KindNodeSet {
+		return fmt.Errorf("xml format requires a node set but got %v", r.Kind())
+	}
+
+	ns := r.Nodes()
+	if len(ns) == 0 {
+		// WriteXML writes the whole tree for no nodes
+		_, err := fmt.Fprint(w, xml.Header+"<astquery></astquery>\n")
+		return err
+	}
+
+	return e.WriteXML(w, ns...)
+}
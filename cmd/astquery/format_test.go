@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/txtar"
+)
+
+func TestOutput(t *testing.T) {
+	TD := func(f string) string { return filepath.Join("testdata", "TestOutput", f) }
+	type run func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error
+	eval := func(expr string) run {
+		return func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error {
+			r, err := e.Evaluate(expr)
+			if err != nil {
+				return err
+			}
+			var m *astquery.Message
+			if flagMsg != "" {
+				m = astquery.MustParseMessage(flagMsg)
+			}
+			return printResult(w, e, fset, expr, r, m)
+		}
+	}
+
+	cases := map[string]struct {
+		set    func()
+		run    run
+		golden string
+	}{
+		"grep":       {func() {}, eval("//*[@type='CallExpr'][Fun/Sel/@Name='Println']"), "grep.golden"},
+		"context":    {func() { flagContext = 1 }, eval("//*[@type='IfStmt']"), "context.golden"},
+		"line":       {func() {}, eval("//*[@type='ReturnStmt']"), "line.golden"},
+		"attr":       {func() { flagPos = true }, eval("//*[@type='BasicLit']/@Value"), "attr.golden"},
+		"number":     {func() {}, eval("count(//*[@type='CallExpr'])"), "number.golden"},
+		"msg":        {func() { flagMsg = "call to {{.Name}}" }, eval("//*[@type='SelectorExpr']/Sel"), "msg.golden"},
+		"json":       {func() { flagFormat = "json" }, eval("//*[@type='SelectorExpr']/Sel"), "json.golden"},
+		"jsonl":      {func() { flagFormat = "jsonl" }, eval("//*[@type='SelectorExpr']/Sel/@Name"), "jsonl.golden"},
+		"sarif":      {func() { flagFormat, flagMsg = "sarif", "call to {{.Name}}" }, eval("//*[@type='SelectorExpr']/Sel[@Name='Errorf']"), "sarif.golden"},
+		"sarifnomsg": {func() { flagFormat = "sarif" }, eval("//*[@type='SelectorExpr']/Sel[@Name='Errorf' or @Name='{{']"), "sarifnomsg.golden"},
+		"xml":        {func() { flagFormat = "xml" }, eval("//*[@type='SelectorExpr']/Sel[@Name='Println']"), "xml.golden"},
+		"noxml":      {func() { flagFormat = "xml" }, eval("//nothing"), "noxml.golden"},
+		"pattern": {func() {}, func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error {
+			return match(w, e, fset, "fmt.Errorf($format, $*args)", nil)
+		}, "pattern.golden"},
+		"where": {func() { flagWhere = namedFlag{"args": "@type='BasicLit'"} }, func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error {
+			return match(w, e, fset, "fmt.Errorf($format, $*args)", nil)
+		}, "where.golden"},
+		"capture": {func() { flagFormat, flagCaptures = "jsonl", namedFlag{"name": "Fun/Sel/@Name", "args": "Args"} }, func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error {
+			return capture(w, e, fset, "//*[@type='CallExpr']", nil)
+		}, "capture.golden"},
+		"rules": {func() {}, func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error {
+			return checkRules(w, e, fset, filepath.Join(dir, "rules.json"))
+		}, "rules.golden"},
+		"rewrite": {func() {}, func(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, dir string) error {
+			return rewrite(w, e, fset, "//*[@type='CallExpr'][Fun/Sel/@Name='Errorf'][count(Args)=1]", "errors.New({{.Args[0]}})")
+		}, "rewrite.golden"},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			// flags are global variables, so the cases are not run in parallel
+			resetFlags(t)
+			flagColor = "never"
+			tt.set()
+
+			e, fset, dir := newFileEvaluator(t, TD("a.go"))
+			var buf bytes.Buffer
+			if err := tt.run(&buf, e, fset, dir); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			// files are in the temporary directory and SARIF logs have the working directory
+			got := strings.ReplaceAll(buf.String(), dir+string(filepath.Separator), "DIR/")
+			if wd, err := os.Getwd(); err == nil {
+				got = strings.ReplaceAll(got, filepath.ToSlash(wd)+"/", "WD/")
+			}
+
+			want, err := ioutil.ReadFile(TD(tt.golden))
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if diff := cmp.Diff(string(want), got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// newFileEvaluator writes the files in the txtar file to a temporary directory and creates an Evaluator.
+// Only .go files are parsed.
+func newFileEvaluator(t *testing.T, path string) (*astquery.Evaluator, *token.FileSet, string) {
+	t.Helper()
+	ar, err := txtar.ParseFile(path)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	dir := t.TempDir()
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, f := range ar.Files {
+		fpath := filepath.Join(dir, f.Name)
+		if err := ioutil.WriteFile(fpath, f.Data, 0o666); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		if filepath.Ext(f.Name) != ".go" {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, fpath, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		files = append(files, file)
+	}
+
+	return astquery.New(fset, files, nil), fset, dir
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gostaticanalysis/astquery"
+)
+
+const (
+	colorMatch = "\x1b[1;31m"
+	colorReset = "\x1b[0m"
+)
+
+// grepPrinter prints nodes like grep -n such as "path/file.go:12:3: source".
+// The lines of a node are printed with their line numbers and
+// context lines are printed as "path/file.go-11- source".
+type grepPrinter struct {
+	w      io.Writer
+	fset   *token.FileSet
+	before int // the number of context lines before a node
+	after  int // the number of context lines after a node
+	color  bool
+	wd     string
+	lines  map[string][][]byte // file name -> lines
+}
+
+func newGrepPrinter(w io.Writer, fset *token.FileSet) *grepPrinter {
+	before, after := flagBefore, flagAfter
+	if flagContext > 0 {
+		if before == 0 {
+			before = flagContext
+		}
+		if after == 0 {
+			after = flagContext
+		}
+	}
+
+	wd, _ := os.Getwd()
+	return &grepPrinter{
+		w:      w,
+		fset:   fset,
+		before: before,
+		after:  after,
+		color:  useColor(),
+		wd:     wd,
+		lines:  make(map[string][][]byte),
+	}
+}
+
+// useColor reports whether matched spans are highlighted by -color flag.
+func useColor() bool {
+	switch flagColor {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	// auto
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0 && os.Getenv("TERM") != "dumb"
+}
+
+func (p *grepPrinter) print(ns []ast.Node) error {
+	for i, n := range ns {
+		if i > 0 && (p.before > 0 || p.after > 0) {
+			fmt.Fprintln(p.w, "--")
+		}
+		if err := p.printNode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *grepPrinter) printNode(n ast.Node) error {
+	if pkg, ok := n.(*astquery.Package); ok {
+		// a package is printed as its path instead of its files
+		_, err := fmt.Fprintln(p.w, pkg)
+		return err
+	}
+
+	if !n.Pos().IsValid() {
+		// such as a package without files
+		_, err := fmt.Fprintf(p.w, "%[1]T %[1]v\n", n)
+		return err
+	}
+
+	// lines are read from the file itself, so positions are not adjusted by //line directives
+	pos, end := p.fset.PositionFor(n.Pos(), false), p.fset.PositionFor(n.End(), false)
+	lines, err := p.readLines(pos.Filename)
+	if err != nil {
+		return err
+	}
+
+	if end.Filename != pos.Filename || end.Line < pos.Line {
+		end = pos
+	}
+
+	filename := p.rel(pos.Filename)
+	first, last := pos.Line-p.before, end.Line+p.after
+	if first < 1 {
+		first = 1
+	}
+	if last > len(lines) {
+		last = len(lines)
+	}
+
+	for l := first; l <= last; l++ {
+		line := lines[l-1]
+		switch {
+		case l < pos.Line || l > end.Line:
+			fmt.Fprintf(p.w, "%s-%d- %s\n", filename, l, line)
+		case l == pos.Line:
+			fmt.Fprintf(p.w, "%s:%d:%d: %s\n", filename, l, pos.Column, p.highlight(line, l, pos, end))
+		default:
+			fmt.Fprintf(p.w, "%s:%d: %s\n", filename, l, p.highlight(line, l, pos, end))
+		}
+	}
+
+	return nil
+}
+
+// highlight highlights the span between pos and end in the l-th line.
+func (p *grepPrinter) highlight(line []byte, l int, pos, end token.Position) []byte {
+	if !p.color {
+		return line
+	}
+
+	start, stop := 0, len(line)
+	if l == pos.Line {
+		start = pos.Column - 1
+	}
+	if l == end.Line {
+		stop = end.Column - 1
+	}
+	if start < 0 || stop > len(line) || start >= stop {
+		return line
+	}
+
+	var buf bytes.Buffer
+	buf.Write(line[:start])
+	buf.WriteString(colorMatch)
+	buf.Write(line[start:stop])
+	buf.WriteString(colorReset)
+	buf.Write(line[stop:])
+	return buf.Bytes()
+}
+
+func (p *grepPrinter) readLines(filename string) ([][]byte, error) {
+	if lines, ok := p.lines[filename]; ok {
+		return lines, nil
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(src, []byte("\n")), []byte("\n"))
+	for i := range lines {
+		lines[i] = bytes.TrimSuffix(lines[i], []byte("\r"))
+	}
+	p.lines[filename] = lines
+
+	return lines, nil
+}
+
+// rel returns a relative path from the working directory if the file is in it.
+func (p *grepPrinter) rel(filename string) string {
+	if p.wd == "" {
+		return filename
+	}
+	rel, err := filepath.Rel(p.wd, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filename
+	}
+	return rel
+}
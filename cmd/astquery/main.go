@@ -1,24 +1,81 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"go/ast"
+	"go/token"
+	"io"
+	"io/ioutil"
 	"os"
-	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/gostaticanalysis/astquery"
 	"golang.org/x/tools/go/packages"
 )
 
+var (
+	flagPos       bool
+	flagTypeNames bool
+	flagRewrite   string
+	flagWrite     bool
+	flagMsg       string
+	flagFormat    string
+	flagRules     string
+	flagAfter     int
+	flagBefore    int
+	flagContext   int
+	flagColor     string
+	flagInteract  bool
+	flagDump      bool
+	flagPattern   string
+	flagCaptures  = namedFlag{}
+	flagWhere     = namedFlag{}
+)
+
+func init() {
+	flag.BoolVar(&flagPos, "pos", false, "print positions and names of attributes")
+	flag.BoolVar(&flagTypeNames, "typenames", false, "use types of nodes as element names instead of field names")
+	flag.StringVar(&flagRewrite, "rewrite", "", "replace selected nodes with the template such as 'errors.New({{.Args[0].src}})' and print diffs")
+	flag.BoolVar(&flagWrite, "w", false, "write rewritten source code to files instead of printing diffs")
+	flag.StringVar(&flagMsg, "msg", "", "print a message for each selected node by the text/template which refers its attributes such as 'call to {{.Name}}'")
+	flag.StringVar(&flagFormat, "format", "text", "output format: text, json, jsonl, sarif or xml")
+	flag.StringVar(&flagRules, "rules", "", "check rules in the JSON file instead of evaluating an expression")
+	flag.IntVar(&flagAfter, "A", 0, "print the number of lines of trailing context after selected nodes")
+	flag.IntVar(&flagBefore, "B", 0, "print the number of lines of leading context before selected nodes")
+	flag.IntVar(&flagContext, "C", 0, "print the number of context lines before and after selected nodes")
+	flag.StringVar(&flagColor, "color", "auto", "highlight selected nodes: auto, always or never")
+	flag.BoolVar(&flagInteract, "i", false, "load packages once and evaluate expressions interactively")
+	flag.BoolVar(&flagDump, "dump", false, "print trees under selected nodes with element names and attributes")
+	flag.StringVar(&flagPattern, "pattern", "", "print nodes which match the Go code pattern with metavariables such as 'fmt.Errorf($msg, $*args)' instead of evaluating an expression")
+	flag.Var(flagCaptures, "capture", "print values of the relative XPath expression such as 'recv=Fun/X' for each selected node (can be repeated)")
+	flag.Var(flagWhere, "where", "constrain nodes bound to the metavariable of -pattern by the XPath expression such as \"err=@typeof='error'\" (can be repeated)")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: astquery [flags] expr [packages]")
+		fmt.Fprintln(os.Stderr, "       astquery -rules file [flags] [packages]")
+		fmt.Fprintln(os.Stderr, "       astquery -pattern pattern [flags] [packages]")
+		fmt.Fprintln(os.Stderr, "       astquery -i [flags] [packages]")
+		flag.PrintDefaults()
+	}
+}
+
 func main() {
+	flag.Parse()
+	if err := validateFlags(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		flag.Usage()
+		os.Exit(2)
+	}
 	expr := "/"
-	pattern := os.Args[1:]
-	if len(os.Args) > 1 {
-		expr = os.Args[1]
-		pattern = os.Args[2:]
+	pattern := flag.Args()
+	if flag.NArg() > 0 && flagRules == "" && flagPattern == "" && !flagInteract {
+		expr = flag.Arg(0)
+		pattern = flag.Args()[1:]
 	}
 
-	cfg := &packages.Config{Mode: packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedDeps}
+	fset := token.NewFileSet()
+	cfg := &packages.Config{Fset: fset, Mode: packages.NeedName | packages.NeedModule | packages.NeedFiles | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo | packages.NeedDeps}
 	pkgs, err := packages.Load(cfg, pattern...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "load: %v\n", err)
@@ -28,34 +85,237 @@ func main() {
 		os.Exit(1)
 	}
 
-	for _, pkg := range pkgs {
-		e := astquery.New(pkg.Fset, pkg.Syntax, nil)
-		v, err := e.Eval(expr)
+	e := newEvaluator(fset, pkgs)
+	if flagRewrite != "" {
+		if err := rewrite(os.Stdout, e, fset, expr, flagRewrite); err != nil {
+			fmt.Fprintf(os.Stderr, "rewrite: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flagInteract {
+		if err := runREPL(e, fset); err != nil {
+			fmt.Fprintf(os.Stderr, "repl: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flagRules != "" {
+		if err := checkRules(os.Stdout, e, fset, flagRules); err != nil {
+			fmt.Fprintf(os.Stderr, "rules: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var m *astquery.Message
+	if flagMsg != "" {
+		m, err = astquery.ParseMessage(flagMsg)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+			fmt.Fprintf(os.Stderr, "msg: %v\n", err)
 			os.Exit(1)
 		}
+	}
 
-		switch v := v.(type) {
-		case []ast.Node:
-			for _, n := range v {
-				fmt.Printf("%[1]T %[1]v\n", n)
-			}
-		default:
-			rv := reflect.ValueOf(v)
-			switch rv.Kind() {
-			case reflect.Array, reflect.Slice:
-				for i := 0; i < rv.Len(); i++ {
-					fmt.Println(rv.Index(i).Interface())
-				}
-			case reflect.Map:
-				for _, key := range rv.MapKeys() {
-					val := rv.MapIndex(key)
-					fmt.Printf("%v:%v\n", key.Interface(), val.Interface())
-				}
-			default:
-				fmt.Println(v)
+	if flagPattern != "" {
+		if err := match(os.Stdout, e, fset, flagPattern, m); err != nil {
+			fmt.Fprintf(os.Stderr, "pattern: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(flagCaptures) > 0 {
+		if err := capture(os.Stdout, e, fset, expr, m); err != nil {
+			fmt.Fprintf(os.Stderr, "capture: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if flagDump {
+		if err := dump(os.Stdout, e, expr); err != nil {
+			fmt.Fprintf(os.Stderr, "dump: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	r, err := e.Evaluate(expr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "eval: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := printResult(os.Stdout, e, fset, expr, r, m); err != nil {
+		fmt.Fprintf(os.Stderr, "print: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func dump(w io.Writer, e *astquery.Evaluator, expr string) error {
+	ns, err := e.Select(expr)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range ns {
+		if err := e.Dump(n, w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func match(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, pattern string, m *astquery.Message) error {
+	p, err := astquery.ParsePattern(pattern)
+	if err != nil {
+		return err
+	}
+
+	for name, expr := range flagWhere {
+		if err := p.Where(name, expr); err != nil {
+			return err
+		}
+	}
+
+	ms, err := e.Match(p)
+	if err != nil {
+		return err
+	}
+
+	matches := make([]*matched, len(ms))
+	for i := range ms {
+		matches[i] = &matched{node: ms[i].Node, bindings: bindings(e, ms[i])}
+	}
+
+	return printMatches(w, e, fset, matches, m)
+}
+
+func capture(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, expr string, m *astquery.Message) error {
+	cs, err := e.Capture(expr, flagCaptures)
+	if err != nil {
+		return err
+	}
+
+	matches := make([]*matched, len(cs))
+	for i := range cs {
+		matches[i] = &matched{node: cs[i].Node, bindings: captures(e, cs[i])}
+	}
+
+	return printMatches(w, e, fset, matches, m)
+}
+
+// namedFlag is a flag of named expressions which can be repeated such as -capture recv=Fun/X -capture name=Fun/Sel/@Name.
+type namedFlag map[string]string
+
+func (f namedFlag) String() string {
+	cs := make([]string, 0, len(f))
+	for name, expr := range f {
+		cs = append(cs, name+"="+expr)
+	}
+	sort.Strings(cs)
+	return strings.Join(cs, ",")
+}
+
+func (f namedFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("must be name=expr but got %q", s)
+	}
+	f[s[:i]] = s[i+1:]
+	return nil
+}
+
+func checkRules(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, path string) error {
+	rules, err := astquery.LoadRulesFile(path)
+	if err != nil {
+		return err
+	}
+
+	findings, err := e.CheckRules(rules)
+	if err != nil {
+		return err
+	}
+
+	return printFindings(w, e, fset, rules, findings)
+}
+
+// validateFlags validates values and combinations of the flags before loading packages.
+func validateFlags() error {
+	switch flagFormat {
+	case "text", "json", "jsonl", "sarif", "xml":
+	default:
+		return fmt.Errorf("unknown format %q", flagFormat)
+	}
+
+	switch flagColor {
+	case "auto", "always", "never":
+	default:
+		return fmt.Errorf("unknown color mode %q", flagColor)
+	}
+
+	if flagAfter < 0 || flagBefore < 0 || flagContext < 0 {
+		return errors.New("the number of context lines must not be negative")
+	}
+
+	if flagWrite && flagRewrite == "" {
+		return errors.New("-w requires -rewrite")
+	}
+
+	if len(flagWhere) > 0 && flagPattern == "" {
+		return errors.New("-where requires -pattern")
+	}
+
+	switch {
+	case flagRules != "" && flagFormat == "xml":
+		return fmt.Errorf("format %q is not supported for rules", flagFormat)
+	case (flagPattern != "" || len(flagCaptures) > 0) && (flagFormat == "sarif" || flagFormat == "xml"):
+		return fmt.Errorf("format %q is not supported for patterns and captures", flagFormat)
+	}
+
+	return nil
+}
+
+// rewrite prints a diff or writes the rewritten source code for each file.
+// The rewrite of a file is released after it is printed, so files are not held in memory together.
+func rewrite(w io.Writer, e *astquery.Evaluator, fset *token.FileSet, expr, template string) error {
+	q, err := astquery.Compile(expr)
+	if err != nil {
+		return err
+	}
+
+	t, err := astquery.ParseTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	return e.ReplaceQueryFunc(q, t, func(r *astquery.Rewrite) error {
+		for _, edit := range r.Edits {
+			for _, n := range edit.Nested {
+				fmt.Fprintf(os.Stderr, "%v: not rewritten because it is in the rewritten node at %v\n", fset.Position(n.Pos()), fset.Position(edit.Pos))
 			}
 		}
+
+		if flagWrite {
+			return ioutil.WriteFile(r.Filename, r.Out, 0o666)
+		}
+
+		_, err := w.Write(diff(r.Src, r.Out, r.Filename))
+		return err
+	})
+}
+
+// newEvaluator creates an Evaluator for the packages with the options which are specified by the flags.
+// The root node always has a Package element for each package even if only one package is loaded,
+// so an expression such as "/*/*/Decls" selects declarations regardless of the number of packages.
+func newEvaluator(fset *token.FileSet, pkgs []*packages.Package) *astquery.Evaluator {
+	var opts []astquery.Option
+	if flagTypeNames {
+		opts = append(opts, astquery.WithTypeNames())
 	}
+	return astquery.NewPackages(fset, pkgs, opts...)
 }
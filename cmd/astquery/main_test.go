@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestValidateFlags(t *testing.T) {
+	cases := map[string]struct {
+		set     func()
+		wantErr bool
+	}{
+		"default":      {func() {}, false},
+		"format":       {func() { flagFormat = "yaml" }, true},
+		"color":        {func() { flagColor = "sometimes" }, true},
+		"context":      {func() { flagContext = -1 }, true},
+		"write":        {func() { flagWrite = true }, true},
+		"rewrite":      {func() { flagWrite, flagRewrite = true, "f()" }, false},
+		"rulesxml":     {func() { flagRules, flagFormat = "rules.json", "xml" }, true},
+		"rulessarif":   {func() { flagRules, flagFormat = "rules.json", "sarif" }, false},
+		"patternsarif": {func() { flagPattern, flagFormat = "f($x)", "sarif" }, true},
+		"capturexml":   {func() { flagCaptures, flagFormat = namedFlag{"x": "X"}, "xml" }, true},
+		"where":        {func() { flagWhere = namedFlag{"x": "true()"} }, true},
+		"wherepattern": {func() { flagWhere, flagPattern = namedFlag{"x": "true()"}, "f($x)" }, false},
+		"capturejsonl": {func() { flagCaptures, flagFormat = namedFlag{"x": "X"}, "jsonl" }, false},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			// flags are global variables, so the cases are not run in parallel
+			resetFlags(t)
+			tt.set()
+			err := validateFlags()
+			switch {
+			case tt.wantErr && err == nil:
+				t.Error("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Error("unexpected error:", err)
+			}
+		})
+	}
+}
+
+func TestNewEvaluator(t *testing.T) {
+	cases := map[string]struct {
+		set  func()
+		pkgs int
+		expr string
+		want interface{}
+	}{
+		"single":    {func() {}, 1, "count(/*/*/Decls)", float64(1)},
+		"multiple":  {func() {}, 2, "count(/*/*/Decls)", float64(2)},
+		"files":     {func() {}, 1, "count(/*/Decls)", float64(0)},
+		"path":      {func() {}, 1, "string(/Package/@path)", "example.com/p0"},
+		"typenames": {func() { flagTypeNames = true }, 1, "count(/Package/File/FuncDecl)", float64(1)},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			// flags are global variables, so the cases are not run in parallel
+			resetFlags(t)
+			tt.set()
+
+			fset := token.NewFileSet()
+			pkgs := make([]*packages.Package, tt.pkgs)
+			for i := range pkgs {
+				name := fmt.Sprintf("p%d", i)
+				f, err := parser.ParseFile(fset, name+".go", "package "+name+"\n\nfunc F() {}\n", 0)
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+				pkgs[i] = &packages.Package{PkgPath: "example.com/" + name, Name: name, Syntax: []*ast.File{f}}
+			}
+
+			got, err := newEvaluator(fset, pkgs).Eval(tt.expr)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// resetFlags sets the default values to the flags and restores them at the end of the test.
+func resetFlags(t *testing.T) {
+	t.Helper()
+	pos, typeNames, msg, format, color, after, before, context := flagPos, flagTypeNames, flagMsg, flagFormat, flagColor, flagAfter, flagBefore, flagContext
+	write, rewrite, rules, pattern, captures, where := flagWrite, flagRewrite, flagRules, flagPattern, flagCaptures, flagWhere
+	t.Cleanup(func() {
+		flagPos, flagTypeNames, flagMsg, flagFormat, flagColor, flagAfter, flagBefore, flagContext = pos, typeNames, msg, format, color, after, before, context
+		flagWrite, flagRewrite, flagRules, flagPattern, flagCaptures, flagWhere = write, rewrite, rules, pattern, captures, where
+	})
+
+	flagPos, flagTypeNames, flagMsg, flagFormat, flagColor, flagAfter, flagBefore, flagContext = false, false, "", "text", "auto", 0, 0, 0
+	flagWrite, flagRewrite, flagRules, flagPattern, flagCaptures, flagWhere = false, "", "", "", namedFlag{}, namedFlag{}
+}
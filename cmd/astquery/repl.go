@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gostaticanalysis/astquery"
+)
+
+const replHelp = `Type an XPath expression to print selected nodes or commands:
+  :count expr   print the number of selected nodes
+  :tree expr    print trees under selected nodes
+  :history      print the history
+  :help         print this help
+  :quit         exit (Ctrl-D also exits)
+Tab completes names of elements and attributes.`
+
+// runREPL reads expressions from stdin and prints their results.
+// If stdin is a terminal but raw mode is not available such as on Windows,
+// lines are read in line mode without editing and completion.
+func runREPL(e *astquery.Evaluator, fset *token.FileSet) error {
+	r := &repl{e: e, fset: fset, w: os.Stdout, errw: os.Stderr}
+
+	var lr lineReader = newPlainReader(os.Stdin, nil)
+	if isTerminal(os.Stdin) {
+		// the history is saved only in interactive mode, not for piped input such as a script
+		r.historyPath = historyFile()
+		r.loadHistory()
+
+		tr, err := newTermReader(os.Stdin, os.Stdout, r.complete)
+		if err != nil {
+			lr = newPlainReader(os.Stdin, os.Stdout)
+		} else {
+			defer tr.close()
+			lr = tr
+		}
+	}
+
+	return r.run(lr)
+}
+
+// run reads lines from lr and executes them until EOF or :quit.
+func (r *repl) run(lr lineReader) error {
+	fmt.Fprintln(r.w, `astquery interactive mode (":help" for help)`)
+	for {
+		line, err := lr.readLine("> ", r.history)
+		if err == io.EOF {
+			fmt.Fprintln(r.w)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		r.addHistory(line)
+
+		if quit := r.exec(line); quit {
+			return nil
+		}
+	}
+}
+
+type repl struct {
+	e           *astquery.Evaluator
+	fset        *token.FileSet
+	w           io.Writer
+	errw        io.Writer
+	history     []string
+	historyPath string // "" if the history is not saved
+}
+
+var replCommands = []string{":count", ":tree", ":history", ":help", ":quit"}
+
+// exec executes a command or an expression and reports whether the REPL should exit.
+func (r *repl) exec(line string) bool {
+	cmd, arg := line, ""
+	if strings.HasPrefix(line, ":") {
+		if i := strings.IndexAny(line, " \t"); i >= 0 {
+			cmd, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+	} else {
+		cmd, arg = "", line
+	}
+
+	var err error
+	switch cmd {
+	case "":
+		err = r.eval(arg)
+	case ":count":
+		err = r.count(arg)
+	case ":tree":
+		err = r.tree(arg)
+	case ":history":
+		for i, h := range r.history {
+			fmt.Fprintf(r.w, "%4d  %s\n", i+1, h)
+		}
+	case ":help":
+		fmt.Fprintln(r.w, replHelp)
+	case ":quit", ":q", ":exit":
+		return true
+	default:
+		err = fmt.Errorf("unknown command %s", cmd)
+	}
+
+	if err != nil {
+		fmt.Fprintln(r.errw, "error:", err)
+	}
+
+	return false
+}
+
+func (r *repl) eval(expr string) error {
+	result, err := r.e.Evaluate(expr)
+	if err != nil {
+		return err
+	}
+	return printResult(r.w, r.e, r.fset, expr, result, nil)
+}
+
+func (r *repl) count(expr string) error {
+	result, err := r.e.Evaluate(expr)
+	if err != nil {
+		return err
+	}
+
+	if result.Kind() != astquery.KindNodeSet {
+		return fmt.Errorf("the result must be a node set but got %v", result.Kind())
+	}
+
+	fmt.Fprintln(r.w, len(result.Nodes())+len(result.Attributes()))
+	return nil
+}
+
+func (r *repl) tree(expr string) error {
+	return dump(r.w, r.e, expr)
+}
+
+// complete returns candidates of the last word of the line.
+func (r *repl) complete(line string) []string {
+	if strings.HasPrefix(line, ":") && !strings.ContainsAny(line, " \t") {
+		var cmds []string
+		for _, c := range replCommands {
+			if strings.HasPrefix(c, line) {
+				cmds = append(cmds, c[1:])
+			}
+		}
+		return cmds
+	}
+
+	if strings.HasPrefix(line, ":") {
+		line = strings.TrimLeft(line[strings.IndexAny(line, " \t"):], " \t")
+	}
+
+	return r.e.Complete(line)
+}
+
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".astquery_history")
+}
+
+func (r *repl) loadHistory() {
+	if r.historyPath == "" {
+		return
+	}
+
+	data, err := ioutil.ReadFile(r.historyPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			r.history = append(r.history, line)
+		}
+	}
+}
+
+func (r *repl) addHistory(line string) {
+	if len(r.history) > 0 && r.history[len(r.history)-1] == line {
+		return
+	}
+	r.history = append(r.history, line)
+
+	if r.historyPath == "" {
+		return
+	}
+
+	f, err := os.OpenFile(r.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, line)
+	f.Close()
+}
+
+// lineReader reads a line with a prompt.
+type lineReader interface {
+	readLine(prompt string, history []string) (string, error)
+}
+
+// plainReader reads lines without editing such as from a pipe.
+type plainReader struct {
+	r *bufio.Reader
+	w io.Writer // prompts are written if it is not nil
+}
+
+func newPlainReader(r io.Reader, w io.Writer) *plainReader {
+	return &plainReader{r: bufio.NewReader(r), w: w}
+}
+
+func (r *plainReader) readLine(prompt string, _ []string) (string, error) {
+	if r.w != nil {
+		fmt.Fprint(r.w, prompt)
+	}
+
+	line, err := r.r.ReadString('\n')
+	if err == io.EOF && line != "" {
+		return line, nil
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), err
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// termReader reads lines from a terminal in raw mode with editing, history and completion.
+// Raw mode is set by stty command, so newTermReader returns an error if stty is not available.
+type termReader struct {
+	r        *bufio.Reader
+	w        io.Writer
+	complete func(line string) []string
+	f        *os.File // nil if the terminal state is not changed
+	state    string   // saved state of stty
+}
+
+func newTermReader(f *os.File, w io.Writer, complete func(line string) []string) (*termReader, error) {
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	state = strings.TrimSpace(state)
+
+	if _, err := stty(f, "-icanon", "-echo", "-isig", "min", "1"); err != nil {
+		stty(f, state) // restore a partially changed state
+		return nil, err
+	}
+
+	return &termReader{
+		r:        bufio.NewReader(f),
+		w:        w,
+		complete: complete,
+		f:        f,
+		state:    state,
+	}, nil
+}
+
+func (r *termReader) close() {
+	if r.f != nil {
+		stty(r.f, r.state)
+	}
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("stty: %w", err)
+	}
+	return string(out), nil
+}
+
+const (
+	keyCtrlA     = 1
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyBackspace = 8
+	keyTab       = 9
+	keyCtrlU     = 21
+	keyEscape    = 27
+	keyDelete    = 127
+)
+
+func (r *termReader) readLine(prompt string, history []string) (string, error) {
+	var (
+		line   []rune
+		cursor int
+		hist   = len(history)
+		edited string // the line which is edited before moving in the history
+	)
+
+	redraw := func() {
+		fmt.Fprintf(r.w, "\r\x1b[K%s%s", prompt, string(line))
+		if back := len(line) - cursor; back > 0 {
+			fmt.Fprintf(r.w, "\x1b[%dD", back)
+		}
+	}
+	redraw()
+
+	for {
+		c, _, err := r.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch c {
+		case '\r', '\n':
+			fmt.Fprintln(r.w)
+			return string(line), nil
+		case keyCtrlD:
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+		case keyCtrlC:
+			fmt.Fprintln(r.w, "^C")
+			line, cursor = nil, 0
+		case keyCtrlA:
+			cursor = 0
+		case keyCtrlE:
+			cursor = len(line)
+		case keyCtrlU:
+			line, cursor = line[cursor:], 0
+		case keyBackspace, keyDelete:
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case keyTab:
+			line, cursor = r.completeLine(prompt, line, cursor)
+		case keyEscape:
+			seq, err := r.escapeSequence()
+			if err != nil {
+				return "", err
+			}
+			switch seq {
+			case "[A": // up
+				if hist > 0 {
+					if hist == len(history) {
+						edited = string(line)
+					}
+					hist--
+					line = []rune(history[hist])
+					cursor = len(line)
+				}
+			case "[B": // down
+				if hist < len(history) {
+					hist++
+					if hist == len(history) {
+						line = []rune(edited)
+					} else {
+						line = []rune(history[hist])
+					}
+					cursor = len(line)
+				}
+			case "[C": // right
+				if cursor < len(line) {
+					cursor++
+				}
+			case "[D": // left
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H":
+				cursor = 0
+			case "[F":
+				cursor = len(line)
+			case "[3~": // delete
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if c == utf8.RuneError || c < ' ' {
+				continue
+			}
+			line = append(line[:cursor], append([]rune{c}, line[cursor:]...)...)
+			cursor++
+		}
+
+		redraw()
+	}
+}
+
+// escapeSequence reads an escape sequence such as "[A" after ESC.
+func (r *termReader) escapeSequence() (string, error) {
+	var seq []rune
+	for {
+		c, _, err := r.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, c)
+
+		switch {
+		case len(seq) == 1 && c != '[' && c != 'O':
+			return string(seq), nil
+		case len(seq) > 1 && (c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c == '~'):
+			if seq[0] == 'O' {
+				seq[0] = '['
+			}
+			return string(seq), nil
+		case len(seq) > 8:
+			return "", errors.New("unknown escape sequence")
+		}
+	}
+}
+
+// completeLine completes the word before the cursor.
+// If there are multiple candidates, it inserts their common prefix and prints them.
+func (r *termReader) completeLine(prompt string, line []rune, cursor int) ([]rune, int) {
+	before := string(line[:cursor])
+	candidates := r.complete(before)
+	if len(candidates) == 0 {
+		return line, cursor
+	}
+
+	// the partially typed word
+	i := len(before)
+	for i > 0 && isWordChar(before[i-1]) {
+		i--
+	}
+	word := before[i:]
+
+	common := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(candidates) > 1 && common == word {
+		fmt.Fprintf(r.w, "\n%s\n", strings.Join(candidates, "  "))
+		return line, cursor
+	}
+
+	insert := []rune(strings.TrimPrefix(common, word))
+	if len(candidates) == 1 && strings.HasPrefix(before, ":") && !strings.ContainsAny(before, " \t") {
+		insert = append(insert, ' ')
+	}
+
+	line = append(line[:cursor], append(insert, line[cursor:]...)...)
+	return line, cursor + len(insert)
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '-' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
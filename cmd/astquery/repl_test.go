@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestREPL(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestREPL", f) }
+	const banner = `astquery interactive mode (":help" for help)` + "\n"
+	cases := map[string]struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		"eof":     {"", banner + "\n", ""},
+		"eval":    {"count(//*[@type='CallExpr'])\n", banner + "1\n\n", ""},
+		"nodes":   {"//*[@type='SelectorExpr']/Sel/@Name\n", banner + "Println\n\n", ""},
+		"count":   {":count //*[@type='Ident']\n", banner + "4\n\n", ""},
+		"tree":    {":tree //*[@type='FuncDecl']/Name\n", banner + `<Name type="Ident" pos="DIR/a.go:5:6" file="DIR/a.go" line="5" col="6" offset="30" endpos="DIR/a.go:5:7" endline="5" endcol="7" src="f" NamePos="DIR/a.go:5:6" Name="f"/>` + "\n\n", ""},
+		"empty":   {"\n \t\n", banner + "\n", ""},
+		"crlf":    {"1+1\r\n", banner + "2\n\n", ""},
+		"noeol":   {"1+1", banner + "2\n\n", ""},
+		"quit":    {":quit\n1+1\n", banner, ""},
+		"history": {"1+1\n1+1\n:history\n", banner + "2\n2\n   1  1+1\n   2  :history\n\n", ""},
+		"help":    {":help\n:q\n", banner + replHelp + "\n", ""},
+		"unknown": {":unknown\n", banner + "\n", "error: unknown command :unknown\n"},
+		"invalid": {"//*[\n:count 1\n", banner + "\n", "error: "},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e, fset, dir := newFileEvaluator(t, TD("a.go"))
+			var w, errw bytes.Buffer
+			r := &repl{e: e, fset: fset, w: &w, errw: &errw}
+			if err := r.run(newPlainReader(strings.NewReader(tt.input), nil)); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			// files are in the temporary directory
+			got := strings.ReplaceAll(w.String(), dir+string(filepath.Separator), "DIR/")
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+
+			if !strings.HasPrefix(errw.String(), tt.wantErr) || (tt.wantErr == "") != (errw.Len() == 0) {
+				t.Errorf("want error output %q but got %q", tt.wantErr, errw.String())
+			}
+		})
+	}
+}
+
+// TestRunREPL_Pipe runs the REPL with piped stdin which must not write the history file.
+// It replaces stdin, stdout and $HOME, so it is not run in parallel.
+func TestRunREPL_Pipe(t *testing.T) {
+	home := t.TempDir()
+	stdin, stdout, oldHome := os.Stdin, os.Stdout, os.Getenv("HOME")
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout = stdin, stdout
+		os.Setenv("HOME", oldHome)
+	})
+	os.Setenv("HOME", home)
+
+	in := filepath.Join(home, "in")
+	if err := ioutil.WriteFile(in, []byte("1+1\n"), 0o666); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	var err error
+	if os.Stdin, err = os.Open(in); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer os.Stdin.Close()
+	if os.Stdout, err = os.Create(filepath.Join(home, "out")); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	defer os.Stdout.Close()
+
+	e, fset, _ := newFileEvaluator(t, filepath.Join("testdata", "TestREPL", "a.go"))
+	if err := runREPL(e, fset); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if _, err := os.Stat(historyFile()); !os.IsNotExist(err) {
+		t.Errorf("history file must not be written for piped input: %v", err)
+	}
+}
+
+func TestREPL_Complete(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestREPL", f) }
+	cases := map[string]struct {
+		line string
+		want []string
+	}{
+		"empty":     {"", nil},
+		"colon":     {":", S("count", "tree", "history", "help", "quit")},
+		"command":   {":h", S("history", "help")},
+		"nocommand": {":x", nil},
+		"arg":       {":count //*[@type='CallExpr']/F", S("Fun")},
+		"argspace":  {":count ", nil},
+		"element":   {"//*[@type='CallExpr']/F", S("Fun")},
+		"attr":      {"//*[@type='Ident']/@Na", S("Name", "NamePos")},
+		"nothing":   {"//*[@type='CallExpr']/Zzz", nil},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e, fset, _ := newFileEvaluator(t, TD("a.go"))
+			r := &repl{e: e, fset: fset}
+			if diff := cmp.Diff(tt.want, r.complete(tt.line)); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestTermReader(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	complete := func(line string) []string {
+		switch {
+		case strings.HasSuffix(line, ":c"):
+			return S("count")
+		case strings.HasSuffix(line, "/F"):
+			return S("Fields", "Fun")
+		}
+		return nil
+	}
+
+	cases := map[string]struct {
+		input   string
+		history []string
+		want    string
+		wantErr error
+	}{
+		"line":       {"abc\r", nil, "abc", nil},
+		"backspace":  {"ab\x7fc\n", nil, "ac", nil},
+		"move":       {"ac\x1b[Db\x01_\x05!\r", nil, "_abc!", nil},
+		"delete":     {"abc\x1b[D\x1b[D\x1b[3~\r", nil, "ac", nil},
+		"clear":      {"ab\x15c\r", nil, "c", nil},
+		"ctrlc":      {"ab\x03c\r", nil, "c", nil},
+		"up":         {"\x1b[A\x1b[A\r", S("a", "b"), "a", nil},
+		"down":       {"x\x1b[A\x1b[B\r", S("a"), "x", nil},
+		"top":        {"\x1b[A\x1b[A\r", S("a"), "a", nil},
+		"command":    {":c\t//*\r", nil, ":count //*", nil},
+		"common":     {"//F\ti\t\r", nil, "//Fi", nil},
+		"nocomplete": {"x\t\r", nil, "x", nil},
+		"control":    {"a\x02b\r", nil, "ab", nil},
+		"ctrld":      {"\x04", nil, "", io.EOF},
+		"ctrldline":  {"a\x04\r", nil, "a", nil},
+		"eof":        {"ab", nil, "", io.EOF},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			var w bytes.Buffer
+			r := &termReader{r: bufio.NewReader(strings.NewReader(tt.input)), w: &w, complete: complete}
+			got, err := r.readLine("> ", tt.history)
+			if err != tt.wantErr {
+				t.Fatalf("want error %v but got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("want %q but got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+-- a.go --
+package a
+
+import "fmt"
+
+func f(s string) error {
+	fmt.Println("hello")
+	if s == "" {
+		return fmt.Errorf("empty")
+	}
+//line b.go:100
+	return fmt.Errorf("error: %s", s)
+}
+-- rules.json --
+{"rules": [
+	{"id": "errorf", "xpath": "//*[@type='CallExpr'][Fun/Sel/@Name='Errorf']", "message": "use errors.New", "severity": "info"}
+]}
@@ -0,0 +1,8 @@
+-- a.go --
+package a
+
+import "fmt"
+
+func f() {
+	fmt.Println("hello")
+}
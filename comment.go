@@ -0,0 +1,62 @@
+package astquery
+
+import (
+	"go/ast"
+	"reflect"
+	"strings"
+)
+
+// commentAttributes returns @doc and @comment attributes of the node.
+// Their values are computed lazily by commentValue.
+func (n *NodeNavigator) commentAttributes(node ast.Node) []attr {
+	var attrs []attr
+
+	doc := docOf(node)
+	if doc != nil {
+		attrs = append(attrs, attr{parent: node, name: "doc", nav: n})
+	}
+
+	for _, cg := range n.idx.comments(node) {
+		if cg != doc {
+			attrs = append(attrs, attr{parent: node, name: "comment", nav: n})
+			break
+		}
+	}
+
+	return attrs
+}
+
+func (n *NodeNavigator) commentValue(node ast.Node, name string) string {
+	doc := docOf(node)
+	if name == "doc" {
+		return commentText(doc)
+	}
+
+	var comments []string
+	for _, cg := range n.idx.comments(node) {
+		if cg != doc {
+			comments = append(comments, commentText(cg))
+		}
+	}
+	return strings.Join(comments, "\n")
+}
+
+// docOf returns a doc comment of the node such as ast.FuncDecl.Doc.
+func docOf(n ast.Node) *ast.CommentGroup {
+	rv := reflect.ValueOf(n)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+
+	f := rv.Elem().FieldByName("Doc")
+	if !f.IsValid() {
+		return nil
+	}
+
+	doc, _ := f.Interface().(*ast.CommentGroup)
+	return doc
+}
+
+func commentText(cg *ast.CommentGroup) string {
+	return strings.TrimSpace(cg.Text())
+}
@@ -0,0 +1,142 @@
+package astquery
+
+import (
+	"sort"
+	"strings"
+)
+
+// Complete returns candidates of names which can complete the last step of the incomplete expression.
+// If the last step is an attribute such as "//*[@ty", the candidates are names of attributes of the context nodes,
+// otherwise they are names of elements which are children of the context nodes such as "Fun" for "//*[@type='CallExpr']/F".
+// The candidates start with the partially typed name and are sorted.
+// If the context nodes cannot be determined, Complete returns nil.
+func (e *Evaluator) Complete(expr string) (names []string) {
+	defer func() {
+		// the context expression may not be evaluated
+		if recover() != nil {
+			names = nil
+		}
+	}()
+
+	i := len(expr)
+	for i > 0 && isNameChar(rune(expr[i-1])) && expr[i-1] != '.' {
+		i--
+	}
+	word, base := expr[i:], expr[:i]
+
+	attr := strings.HasSuffix(base, "@")
+	base = strings.TrimSuffix(base, "@")
+
+	ctx, ok := contextExpr(base)
+	if !ok {
+		return nil
+	}
+
+	q, err := Compile(ctx)
+	if err != nil {
+		return nil
+	}
+
+	n, err := e.navigator(q)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	iter := n.query.expr.Select(n)
+	for iter.MoveNext() {
+		n, _ := iter.Current().Copy().(*NodeNavigator)
+		if n == nil || n.attr != -1 {
+			continue
+		}
+
+		if attr {
+			for n.MoveToNextAttribute() {
+				if n.Prefix() == "" {
+					seen[n.LocalName()] = true
+				}
+			}
+			continue
+		}
+
+		for ok := n.MoveToChild(); ok; ok = n.MoveToNext() {
+			seen[n.LocalName()] = true
+		}
+	}
+
+	for name := range seen {
+		if strings.HasPrefix(name, word) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// contextExpr returns an expression which selects the context nodes of the next step.
+// base is the expression without the last incomplete step.
+func contextExpr(base string) (string, bool) {
+	if base == "" {
+		return "", false
+	}
+
+	switch {
+	case base == "/":
+		return "/", true
+	case base == "//":
+		return "/descendant-or-self::node()", true
+	case strings.HasSuffix(base, "//"):
+		return strings.TrimSuffix(base, "//") + "/descendant-or-self::node()", true
+	case strings.HasSuffix(base, "/") && unclosedBracket(base) < 0:
+		return strings.TrimSuffix(base, "/"), true
+	}
+
+	// in a predicate such as "//*[Fun/"
+	open := unclosedBracket(base)
+	if open < 0 {
+		return "", false
+	}
+	outer, inner := base[:open], base[open+1:]
+
+	i := len(inner)
+	for i > 0 && !strings.ContainsRune(" ([,=<>!|", rune(inner[i-1])) {
+		i--
+	}
+	rel := inner[i:]
+
+	switch {
+	case rel == "":
+		return outer, true
+	case strings.HasSuffix(rel, "/") && !strings.HasSuffix(rel, "//"):
+		return outer + "/" + strings.TrimSuffix(rel, "/"), true
+	}
+
+	return "", false
+}
+
+// unclosedBracket returns the index of the innermost unclosed '[' which is not in a string literal.
+// If there is no unclosed bracket, it returns -1.
+func unclosedBracket(s string) int {
+	var stack []int
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			stack = append(stack, i)
+		case c == ']' && len(stack) > 0:
+			stack = stack[:len(stack)-1]
+		}
+	}
+
+	if len(stack) == 0 {
+		return -1
+	}
+	return stack[len(stack)-1]
+}
@@ -0,0 +1,45 @@
+package astquery_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEvaluator_Complete(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Complete", f) }
+	cases := map[string]struct {
+		path string
+		expr string
+		want []string
+	}{
+		"root":      {TD("a.go"), "/", S("a.go")},
+		"children":  {TD("a.go"), "//*[@type='CallExpr']/", S("Args", "Fun")},
+		"prefix":    {TD("a.go"), "//*[@type='CallExpr']/F", S("Fun")},
+		"attrs":     {TD("a.go"), "//*[@type='CallExpr']/Fun/@N", S("Name", "NamePos")},
+		"predicate": {TD("a.go"), "//*[@type='CallExpr'][@ty", S("type", "typeof")},
+		"relative":  {TD("a.go"), "//*[@type='CallExpr'][Fun/@Na", S("Name", "NamePos")},
+		"string":    {TD("a.go"), "//*[@type='CallExpr' and @src!='[']/F", S("Fun")},
+		"desc":      {TD("a.go"), "//B", S("Body")},
+		"descattr":  {TD("a.go"), "//@Nam", S("Name", "NamePos")},
+		"empty":     {TD("a.go"), "", nil},
+		"invalid":   {TD("a.go"), "//*[@type=]/", nil},
+		"nomatch":   {TD("a.go"), "//*[@type='CallExpr']/X", nil},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			got := e.Complete(tt.expr)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
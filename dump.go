@@ -0,0 +1,139 @@
+package astquery
+
+import (
+	"fmt"
+	"go/ast"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/antchfx/xpath"
+)
+
+// maxDumpValue is the maximum length of an attribute value which is printed by Dump.
+const maxDumpValue = 60
+
+// Dump prints the tree under the node as indented XML-like text which shows
+// element names and attributes which can be used in XPath expressions.
+// An element which is an element of a slice field has its position such as Args[1].
+// Long attribute values are truncated.
+// If the node is nil, Dump prints the whole tree.
+//
+// Example:
+//
+//	<CallExpr type="CallExpr" pos="a.go:4:2" ...>
+//	  <Fun type="Ident" pos="a.go:4:2" ... Name="println"/>
+//	  <Args[1] type="BasicLit" pos="a.go:4:10" ... Kind="STRING" Value="\"hello\""/>
+//	</CallExpr>
+func (e *Evaluator) Dump(node ast.Node, w io.Writer) error {
+	n := e.n.Copy().(*NodeNavigator)
+	if node != nil && !n.moveToNode(node) {
+		return fmt.Errorf("node %T is not in the tree", node)
+	}
+
+	d := &dumper{w: w}
+	if n.NodeType() == xpath.RootNode {
+		for ok := n.MoveToChild(); ok; ok = n.MoveToNext() {
+			d.dump(n.Copy().(*NodeNavigator), 0)
+		}
+	} else {
+		d.dump(n, 0)
+	}
+
+	return d.err
+}
+
+// moveToNode moves the navigator to the node.
+func (n *NodeNavigator) moveToNode(node ast.Node) bool {
+	var stack []ast.Node
+	switch node := node.(type) {
+	case *pkg:
+		n.MoveToRoot()
+		return node == n.root
+	case *Package:
+		stack = []ast.Node{node}
+	default:
+		stack = n.idx.stack(node)
+		if len(stack) == 0 {
+			return false
+		}
+		if f, ok := stack[0].(*ast.File); ok {
+			if p := n.root.packageOf(f); p != nil {
+				stack = append([]ast.Node{p}, stack...)
+			}
+		}
+	}
+
+	n.MoveToRoot()
+	for _, target := range stack {
+		if !n.MoveToChild() {
+			return false
+		}
+		for n.node != target {
+			if !n.MoveToNext() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format, args...)
+	}
+}
+
+func (d *dumper) dump(n *NodeNavigator, depth int) {
+	indent := strings.Repeat("  ", depth)
+	name := n.LocalName()
+	if n.idx.index(n.node) >= 0 {
+		name = fmt.Sprintf("%s[%d]", name, n.position())
+	}
+
+	d.printf("%s<%s", indent, name)
+	attrs := n.Copy().(*NodeNavigator)
+	for attrs.MoveToNextAttribute() {
+		d.printf(" %s=%s", attrs.LocalName(), dumpValue(attrs.Value()))
+	}
+
+	child := n.Copy().(*NodeNavigator)
+	if !child.MoveToChild() {
+		d.printf("/>\n")
+		return
+	}
+	d.printf(">\n")
+
+	for ok := true; ok; ok = child.MoveToNext() {
+		d.dump(child.Copy().(*NodeNavigator), depth+1)
+	}
+
+	d.printf("%s</%s>\n", indent, name)
+}
+
+// position returns the position of the node in its siblings which have the same name.
+// It is same as the position in XPath such as Args[1].
+func (n *NodeNavigator) position() int {
+	name := n.LocalName()
+	pos := 1
+	prev := n.Copy().(*NodeNavigator)
+	for prev.MoveToPrevious() {
+		if prev.LocalName() == name {
+			pos++
+		}
+	}
+	return pos
+}
+
+func dumpValue(v string) string {
+	if utf8.RuneCountInString(v) > maxDumpValue {
+		v = string([]rune(v)[:maxDumpValue]) + "..."
+	}
+	return fmt.Sprintf("%q", v)
+}
@@ -0,0 +1,89 @@
+package astquery_test
+
+import (
+	"bytes"
+	"go/ast"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestEvaluator_Dump(t *testing.T) {
+	t.Parallel()
+
+	O := func(opts ...astquery.Option) []astquery.Option { return opts }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Dump", f) }
+	cases := map[string]struct {
+		path    string
+		opts    []astquery.Option
+		xpath   string
+		golden  string
+		wantErr bool
+	}{
+		"call":      {TD("a.go"), nil, "//*[@type='CallExpr']", TD("call.golden"), false},
+		"typenames": {TD("a.go"), O(astquery.WithTypeNames()), "//FieldList", TD("typenames.golden"), false},
+		"notfound":  {TD("a.go"), nil, "", "", true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path, tt.opts...)
+
+			var node ast.Node = &ast.Ident{Name: "notfound"}
+			if tt.xpath != "" {
+				var err error
+				node, err = e.SelectOne(tt.xpath)
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+			}
+
+			var buf bytes.Buffer
+			err := e.Dump(node, &buf)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			want, err := ioutil.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if diff := cmp.Diff(string(want), trimNewFields(buf.String())); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Dump_All(t *testing.T) {
+	t.Parallel()
+
+	e := newEvaluator(t, filepath.Join("testdata", "TestEvaluator_Dump", "a.go"))
+	var buf bytes.Buffer
+	if err := e.Dump(nil, &buf); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	got := buf.String()
+	if !strings.HasPrefix(got, `<a.go type="File"`) || !strings.HasSuffix(got, "</a.go>\n") {
+		t.Errorf("unexpected dump:\n%s", got)
+	}
+
+	for _, elem := range []string{`<Decls[1] type="FuncDecl"`, `<X type="CallExpr"`, `</Decls[1]>`} {
+		if !strings.Contains(got, elem) {
+			t.Errorf("dump does not contain %s:\n%s", elem, got)
+		}
+	}
+}
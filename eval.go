@@ -5,7 +5,6 @@ import (
 	"go/ast"
 	"go/token"
 
-	"github.com/antchfx/xpath"
 	"golang.org/x/tools/go/ast/inspector"
 )
 
@@ -16,48 +15,94 @@ type Evaluator struct {
 
 // New creates an Evaluator.
 // If the given inspector is not nil macher use it.
-func New(fset *token.FileSet, files []*ast.File, in *inspector.Inspector) *Evaluator {
-	return &Evaluator{n: NewNodeNavigator(fset, files, in)}
+func New(fset *token.FileSet, files []*ast.File, in *inspector.Inspector, opts ...Option) *Evaluator {
+	return &Evaluator{n: NewNodeNavigator(fset, files, in, opts...)}
+}
+
+// RegisterFunc registers a function which can be called in XPath expressions.
+// The name must be a qualified name such as "my:func" and the prefix "go" is reserved.
+// The kind is the kind of the result which is one of KindBool, KindNumber and KindString.
+// If the name or the kind is invalid, RegisterFunc panics.
+// RegisterFunc must not be called concurrently with evaluations.
+func (e *Evaluator) RegisterFunc(name string, kind Kind, fn Func) {
+	e.n.registerFunc(name, kind, fn)
 }
 
 // Eval returns the result of the expression.
 // The result type of the expression is one of the follow: bool,float64,string,[]ast.Node.
 func (e *Evaluator) Eval(expr string) (interface{}, error) {
-	n := e.n.Copy()
-	_expr, err := xpath.Compile(expr)
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.EvalQuery(q)
+}
+
+// EvalQuery returns the result of the compiled query.
+// The result type is same as Eval.
+func (e *Evaluator) EvalQuery(q *Query) (interface{}, error) {
+	r, err := e.EvaluateQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return r.value(), nil
+}
+
+// Evaluate returns the result of the expression as a Result.
+func (e *Evaluator) Evaluate(expr string) (*Result, error) {
+	q, err := Compile(expr)
 	if err != nil {
-		return nil, fmt.Errorf("expr cannot compile: %w", err)
+		return nil, err
 	}
+	return e.EvaluateQuery(q)
+}
 
-	v := _expr.Evaluate(n)
-	switch v := v.(type) {
-	case *xpath.NodeIterator:
-		ns := nodes(v)
-		vs := make([]interface{}, 0, len(ns))
-		for i := range ns {
-			switch n := ns[i].(type) {
-			case attr:
-				vs = append(vs, n.val)
-			}
-		}
-		if len(vs) == len(ns) {
-			return vs, nil
-		}
-		return ns, nil
+// EvaluateQuery returns the result of the compiled query as a Result.
+func (e *Evaluator) EvaluateQuery(q *Query) (_ *Result, rerr error) {
+	defer recoverEval(&rerr)
+	n, err := e.navigator(q)
+	if err != nil {
+		return nil, err
 	}
+	return newResult(e.n.fset, n.query.expr.Evaluate(n)), nil
+}
 
-	return v, nil
+// Attributes returns attributes which match the XPath expr with their owner nodes and positions.
+// Element nodes which match the expr are ignored.
+func (e *Evaluator) Attributes(expr string) ([]*Attribute, error) {
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	return e.AttributesQuery(q)
+}
+
+// AttributesQuery returns attributes which match the compiled query.
+func (e *Evaluator) AttributesQuery(q *Query) ([]*Attribute, error) {
+	r, err := e.EvaluateQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return r.Attributes(), nil
 }
 
 // Select selects a node set which match the XPath expr.
 func (e *Evaluator) Select(expr string) ([]ast.Node, error) {
-	n := e.n.Copy()
-	_expr, err := xpath.Compile(expr)
+	q, err := Compile(expr)
 	if err != nil {
-		return nil, fmt.Errorf("expr cannot compile: %w", err)
+		return nil, err
 	}
+	return e.SelectQuery(q)
+}
 
-	return nodes(_expr.Select(n)), nil
+// SelectQuery selects a node set which match the compiled query.
+func (e *Evaluator) SelectQuery(q *Query) (_ []ast.Node, rerr error) {
+	defer recoverEval(&rerr)
+	n, err := e.navigator(q)
+	if err != nil {
+		return nil, err
+	}
+	return nodes(n.query.expr.Select(n)), nil
 }
 
 // SelectOne selects a node set which match the XPath expr and return the first node.
@@ -71,3 +116,25 @@ func (e *Evaluator) SelectOne(expr string) (ast.Node, error) {
 	}
 	return ns[0], nil
 }
+
+// navigator returns a copy of the NodeNavigator which evaluates the function calls in the query.
+// The query is compiled with the functions which are registered to the Evaluator.
+func (e *Evaluator) navigator(q *Query) (*NodeNavigator, error) {
+	cq, err := q.compileFor(e.n)
+	if err != nil {
+		return nil, err
+	}
+
+	n := e.n.Copy().(*NodeNavigator)
+	n.calls = q.calls
+	n.query = cq
+	return n, nil
+}
+
+// recoverEval converts a panic which is occurred in evaluation of a query into an error.
+// xpath package panics when an expression cannot be evaluated such as a type mismatch.
+func recoverEval(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("expr cannot evaluate: %v", r)
+	}
+}
@@ -1,10 +1,12 @@
 package astquery_test
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
 )
 
 func TestEvaluator_Select(t *testing.T) {
@@ -12,23 +14,28 @@ func TestEvaluator_Select(t *testing.T) {
 	//astquery.DebugON(t)
 
 	S := func(s ...string) []string { return s }
+	O := func(opts ...astquery.Option) []astquery.Option { return opts }
 	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Select", f) }
 	cases := map[string]struct {
 		path  string
 		xpath string
+		opts  []astquery.Option
 		want  []string
 	}{
-		"single":   {TD("single.go"), "/*/Decls[1]/Body/*", S("ReturnStmt")},
-		"multi":    {TD("multi.go"), "/*/Decls[1]/Body/*", S("AssignStmt", "ReturnStmt")},
-		"filename": {TD("single.go"), "/a.go/Decls[1]/Body/*", S("ReturnStmt")},
-		"attr":     {TD("attr.go"), "//*[@type='CallExpr']/Fun[@type='Ident' and @Name='print']", S("Ident", "Ident", "Ident")},
+		"single":    {TD("single.go"), "/*/Decls[1]/Body/*", nil, S("ReturnStmt")},
+		"multi":     {TD("multi.go"), "/*/Decls[1]/Body/*", nil, S("AssignStmt", "ReturnStmt")},
+		"filename":  {TD("single.go"), "/a.go/Decls[1]/Body/*", nil, S("ReturnStmt")},
+		"attr":      {TD("attr.go"), "//*[@type='CallExpr']/Fun[@type='Ident' and @Name='print']", nil, S("Ident", "Ident", "Ident")},
+		"fileattr":  {TD("single.go"), "/*[@type='File']", nil, S("File")},
+		"typenames": {TD("attr.go"), "//CallExpr/Ident[@field='Fun' and @Name='print']", O(astquery.WithTypeNames()), S("Ident", "Ident", "Ident")},
+		"typefile":  {TD("multi.go"), "/File/FuncDecl/BlockStmt/*", O(astquery.WithTypeNames()), S("AssignStmt", "ReturnStmt")},
 	}
 
 	for n, tt := range cases {
 		tt := tt
 		t.Run(n, func(t *testing.T) {
 			t.Parallel()
-			e := newEvaluator(t, tt.path)
+			e := newEvaluator(t, tt.path, tt.opts...)
 			ns, err := e.Select(tt.xpath)
 			if err != nil {
 				t.Fatal("unexpected error:", err)
@@ -51,8 +58,30 @@ func TestEvaluator_Eval(t *testing.T) {
 		xpath string
 		want  interface{}
 	}{
-		"attr": {TD("attr.go"), "//*[@type='CallExpr']/Fun[@type='Ident']/@Name", []interface{}{"print", "print", "println", "print"}},
-		"src": {TD("attr.go"), "//*[@src='print']/@Name", []interface{}{"print", "print", "print"}},
+		"attr":     {TD("attr.go"), "//*[@type='CallExpr']/Fun[@type='Ident']/@Name", []interface{}{"print", "print", "println", "print"}},
+		"src":      {TD("attr.go"), "//*[@src='print']/@Name", []interface{}{"print", "print", "print"}},
+		"typeof":   {TD("types.go"), "//*[@type='SelectorExpr' and @obj='(a.T).Close']/@typeof", []interface{}{"func() error"}},
+		"objkind":  {TD("types.go"), "//*[@type='Ident' and @objkind='typename']/@Name", []interface{}{"T", "T", "error", "T"}},
+		"pkgpath":  {TD("types.go"), "//*[@type='SelectorExpr' and @pkgpath='errors']/@obj", []interface{}{"errors.New"}},
+		"pkgname":  {TD("types.go"), "//*[@objkind='pkgname']/@src", []interface{}{`"errors"`, "errors"}},
+		"doc":      {TD("comment.go"), "//*[@type='FuncDecl' and not(starts-with(string(@doc), string(Name/@Name)))]/Name/@Name", []interface{}{"G"}},
+		"comments": {TD("comment.go"), "//Comments/List/@Text", []interface{}{"// TODO: implement", "// print newline"}},
+		"comment":  {TD("comment.go"), "//*[@type='ExprStmt']/@comment", []interface{}{"TODO: implement\nprint newline"}},
+		"cmtorder": {TD("comment.go"), "//*[@type='ExprStmt']/*/@type", []interface{}{"CommentGroup", "CallExpr", "CommentGroup"}},
+		"cmtnext":  {TD("comment.go"), "//*[@type='ExprStmt']/X/following-sibling::*/List/@Text", []interface{}{"// print newline"}},
+		"cmtfield": {TD("commentfield.go"), "//*[@type='FuncDecl']/*/@type", []interface{}{"CommentGroup", "FieldList", "Ident", "FuncType", "BlockStmt"}},
+		"line":     {TD("position.go"), "//*[@type='FuncDecl' and number(@endline) - number(@line) > 2]/Name/@Name", []interface{}{"g"}},
+		"col":      {TD("position.go"), "//*[@type='CallExpr' and @line >= 8 and @line <= 9]/@col", []interface{}{"2", "2"}},
+		"offset":   {TD("position.go"), "//*[@type='FuncDecl' and @offset > 20]/Name/@Name", []interface{}{"g"}},
+		"endpos":   {TD("position.go"), "//*[@type='FuncDecl']/@endpos", []interface{}{"a.go:5:2", "a.go:11:2"}},
+		"op":       {TD("fields.go"), "//*[@type='BinaryExpr' and @Op='==']/@src", []interface{}{"a == b"}},
+		"tok":      {TD("fields.go"), "//*[@type='AssignStmt']/@Tok", []interface{}{":=", "="}},
+		"dir":      {TD("fields.go"), "//*[@type='ChanType' and @Dir='send']/@src", []interface{}{"chan<- int"}},
+		"tokpos":   {TD("fields.go"), "//*[@type='BinaryExpr']/@OpPos", []interface{}{"a.go:4:9", "a.go:6:7"}},
+		"idents":   {TD("fields.go"), "//*[@type='Field']/@Names", []interface{}{"c", "a,b", "xs"}},
+		"ellipsis": {TD("fields.go"), "//*[@type='CallExpr' and @Ellipsis]/@src", []interface{}{"g(xs...)"}},
+		"nopos":    {TD("fields.go"), "//*[@type='CallExpr' and not(@Ellipsis)]/@Lparen", []interface{}{"a.go:13:3"}},
+		"file":     {TD("position.go"), "//*[@type='FuncDecl' and @file='a.go']/@endcol", []interface{}{"2", "2"}},
 	}
 
 	for n, tt := range cases {
@@ -70,3 +99,38 @@ func TestEvaluator_Eval(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluator_Attributes(t *testing.T) {
+	t.Parallel()
+	//astquery.DebugON(t)
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Eval", f) }
+	cases := map[string]struct {
+		path  string
+		xpath string
+		want  []string
+	}{
+		"attr":  {TD("attr.go"), "//*[@type='CallExpr']/Fun[@type='Ident']/@Name", S("a.go:3:2 Name=print", "a.go:4:2 Name=print", "a.go:5:2 Name=println", "b.go:3:2 Name=print")},
+		"nodes": {TD("attr.go"), "//*[@type='CallExpr']/Fun", nil},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			attrs, err := e.Attributes(tt.xpath)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			var got []string
+			for _, a := range attrs {
+				got = append(got, fmt.Sprintf("%v %s=%s", a.Position, a.Name, a.Value))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
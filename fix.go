@@ -0,0 +1,73 @@
+package astquery
+
+import (
+	"go/ast"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Diagnostic returns a diagnostic which reports the node with the message.
+// If the template is not nil, the diagnostic has a suggested fix which replaces the node with the template.
+// The message is also used as the message of the suggested fix.
+func (e *Evaluator) Diagnostic(n ast.Node, msg string, t *Template) (analysis.Diagnostic, error) {
+	d := analysis.Diagnostic{
+		Pos:     n.Pos(),
+		End:     n.End(),
+		Message: msg,
+	}
+
+	if t == nil {
+		return d, nil
+	}
+
+	fix, err := e.SuggestedFix(msg, []ast.Node{n}, t)
+	if err != nil {
+		return analysis.Diagnostic{}, err
+	}
+	d.SuggestedFixes = []analysis.SuggestedFix{fix}
+
+	return d, nil
+}
+
+// Diagnostics returns diagnostics for nodes which match the compiled query.
+// See Diagnostic.
+func (e *Evaluator) Diagnostics(q *Query, msg string, t *Template) ([]analysis.Diagnostic, error) {
+	ns, err := e.SelectQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	ds := make([]analysis.Diagnostic, 0, len(ns))
+	for _, n := range ns {
+		d, err := e.Diagnostic(n, msg, t)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+
+	return ds, nil
+}
+
+// SuggestedFix returns a suggested fix which replaces the nodes with the template.
+// See Edits.
+func (e *Evaluator) SuggestedFix(msg string, ns []ast.Node, t *Template) (analysis.SuggestedFix, error) {
+	edits, err := e.Edits(ns, t)
+	if err != nil {
+		return analysis.SuggestedFix{}, err
+	}
+	return analysis.SuggestedFix{Message: msg, TextEdits: TextEdits(edits)}, nil
+}
+
+// TextEdits converts the edits to analysis.TextEdit.
+func TextEdits(edits []*Edit) []analysis.TextEdit {
+	tes := make([]analysis.TextEdit, len(edits))
+	for i := range edits {
+		tes[i] = analysis.TextEdit{
+			Pos:     edits[i].Pos,
+			End:     edits[i].End,
+			NewText: edits[i].NewText,
+		}
+	}
+	return tes
+}
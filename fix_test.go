@@ -0,0 +1,78 @@
+package astquery_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestEvaluator_Diagnostics(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Diagnostics", f) }
+	cases := map[string]struct {
+		path     string
+		xpath    string
+		template string
+		want     []string // new texts of suggested fixes
+		wantErr  bool
+	}{
+		"fix":     {TD("errorf.go"), "//*[@type='CallExpr'][Fun/Sel/@Name='Errorf' and count(Args)=1]", "errors.New({{.Args[0]}})", S(`errors.New("static")`, `errors.New("error")`), false},
+		"nofix":   {TD("errorf.go"), "//*[@type='CallExpr'][Fun/Sel/@Name='Errorf' and count(Args)=1]", "", nil, false},
+		"nomatch": {TD("errorf.go"), "//*[@type='CallExpr'][Fun/Sel/@Name='Println']", "errors.New({{.Args[0]}})", nil, false},
+		"invalid": {TD("errorf.go"), "//*[@type='CallExpr'][Fun/Sel/@Name='Errorf']", "errors.New({{.Args[3]}})", nil, true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			q := astquery.MustCompile(tt.xpath)
+			var tmpl *astquery.Template
+			if tt.template != "" {
+				tmpl = astquery.MustParseTemplate(tt.template)
+			}
+
+			ds, err := e.Diagnostics(q, "use errors.New", tmpl)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			ns, err := e.SelectQuery(q)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if len(ds) != len(ns) {
+				t.Fatalf("the number of diagnostics want %d got %d", len(ns), len(ds))
+			}
+
+			var got []string
+			for i, d := range ds {
+				if d.Pos != ns[i].Pos() || d.End != ns[i].End() || d.Message != "use errors.New" {
+					t.Errorf("unexpected diagnostic: %#v", d)
+				}
+				for _, fix := range d.SuggestedFixes {
+					for _, edit := range fix.TextEdits {
+						if edit.Pos != ns[i].Pos() || edit.End != ns[i].End() {
+							t.Errorf("unexpected text edit: %#v", edit)
+						}
+						got = append(got, string(edit.NewText))
+					}
+				}
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
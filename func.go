@@ -0,0 +1,600 @@
+package astquery
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xpath"
+)
+
+// FuncContext is a context of a function call in an XPath expression.
+type FuncContext struct {
+	Node      ast.Node // current node
+	Fset      *token.FileSet
+	Pkg       *types.Package // it may be nil
+	TypesInfo *types.Info    // it may be nil
+}
+
+// Func is a function which can be called in an XPath expression.
+// An argument is one of bool, float64, string and *Result.
+// A node-set is given as *Result.
+// The result must be one of bool, float64, string and nil.
+// It is converted to the kind of the function which is one of KindBool, KindNumber and KindString.
+// nil is converted to false, NaN or an empty string.
+// At the root node, FuncContext.Node is nil.
+type Func func(ctx *FuncContext, args ...interface{}) interface{}
+
+// value converts a result of a function into a string which is the value of its attribute.
+func (k Kind) value(v interface{}) string {
+	switch k {
+	case KindBool:
+		var b bool
+		switch v := v.(type) {
+		case bool:
+			b = v
+		case float64:
+			b = v != 0 && !math.IsNaN(v)
+		case string:
+			b = v != ""
+		}
+		return strconv.FormatBool(b)
+	case KindNumber:
+		switch v := v.(type) {
+		case nil:
+			return "NaN"
+		case bool:
+			if v {
+				return "1"
+			}
+			return "0"
+		}
+	}
+	return argString(v)
+}
+
+// expr returns an expression which refers to the attribute and has the kind.
+func (k Kind) expr(attr string) string {
+	switch k {
+	case KindBool:
+		return fmt.Sprintf("(%s='true')", attr)
+	case KindNumber:
+		return fmt.Sprintf("number(%s)", attr)
+	}
+	return fmt.Sprintf("string(%s)", attr)
+}
+
+type funcDef struct {
+	fn      Func
+	kind    Kind
+	minArgs int
+	maxArgs int // -1 means no limit
+}
+
+// goFuncs is the library of functions which are prefixed by "go".
+var goFuncs = map[string]*funcDef{
+	"exported":     {fn: goExported, kind: KindBool, minArgs: 0, maxArgs: 1},
+	"line":         {fn: goLine, kind: KindNumber, minArgs: 0, maxArgs: 0},
+	"file":         {fn: goFile, kind: KindString, minArgs: 0, maxArgs: 0},
+	"is-test-file": {fn: goIsTestFile, kind: KindBool, minArgs: 0, maxArgs: 0},
+	"implements":   {fn: goImplements, kind: KindBool, minArgs: 2, maxArgs: 2},
+}
+
+// goExported reports whether the name is exported.
+// If no argument is given, it uses the name of the current node.
+func goExported(ctx *FuncContext, args ...interface{}) interface{} {
+	if len(args) == 0 {
+		return token.IsExported(nameOf(ctx.Node))
+	}
+	return token.IsExported(argString(args[0]))
+}
+
+// nameOf returns the name of an identifier or the identifier of a declaration.
+func nameOf(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Ident:
+		return n.Name
+	case *ast.FuncDecl:
+		return n.Name.Name
+	case *ast.TypeSpec:
+		return n.Name.Name
+	}
+	return ""
+}
+
+// goLine returns the line number of the current node.
+func goLine(ctx *FuncContext, _ ...interface{}) interface{} {
+	if ctx.Node == nil {
+		return nil
+	}
+	return float64(ctx.Fset.Position(ctx.Node.Pos()).Line)
+}
+
+// goFile returns the file name of the current node.
+func goFile(ctx *FuncContext, _ ...interface{}) interface{} {
+	if ctx.Node == nil {
+		return nil
+	}
+	return ctx.Fset.Position(ctx.Node.Pos()).Filename
+}
+
+// goIsTestFile reports whether the current node is in a test file.
+func goIsTestFile(ctx *FuncContext, _ ...interface{}) interface{} {
+	if ctx.Node == nil {
+		return false
+	}
+	return strings.HasSuffix(ctx.Fset.Position(ctx.Node.Pos()).Filename, "_test.go")
+}
+
+// goImplements reports whether the type implements the interface.
+// The first argument is a type or an expression such as @typeof.
+// The second argument is a name of an interface such as "io.Reader".
+func goImplements(ctx *FuncContext, args ...interface{}) interface{} {
+	typ := argType(ctx, args[0])
+	if typ == nil {
+		return false
+	}
+
+	iface := argType(ctx, args[1])
+	if iface == nil {
+		return false
+	}
+
+	it, ok := iface.Underlying().(*types.Interface)
+	if !ok {
+		return false
+	}
+
+	return types.Implements(typ, it)
+}
+
+func argString(arg interface{}) string {
+	switch arg := arg.(type) {
+	case string:
+		return arg
+	case bool:
+		return strconv.FormatBool(arg)
+	case float64:
+		return strconv.FormatFloat(arg, 'f', -1, 64)
+	case *Result:
+		// string value of the first node
+		if len(arg.nodes) == 0 {
+			return ""
+		}
+		if a, ok := arg.nodes[0].(attr); ok {
+			return a.value()
+		}
+		return fmt.Sprint(arg.nodes[0])
+	}
+	return ""
+}
+
+// argType returns a type of the argument.
+// If the argument is a node-set, it returns the type of the first node.
+// If the argument is an attribute, it returns the type of its owner.
+// Otherwise the argument is treated as a name of a type.
+func argType(ctx *FuncContext, arg interface{}) types.Type {
+	if r, ok := arg.(*Result); ok && len(r.nodes) != 0 {
+		n := r.nodes[0]
+		if a, ok := n.(attr); ok {
+			n = a.parent
+		}
+		if expr, ok := n.(ast.Expr); ok && ctx.TypesInfo != nil {
+			if typ := ctx.TypesInfo.TypeOf(expr); typ != nil {
+				return typ
+			}
+		}
+	}
+	return lookupType(ctx.Pkg, argString(arg))
+}
+
+// lookupType finds a type by the name such as "*os.File" or "error"
+// from the package and its imports.
+func lookupType(pkg *types.Package, name string) types.Type {
+	if strings.HasPrefix(name, "*") {
+		typ := lookupType(pkg, name[1:])
+		if typ == nil {
+			return nil
+		}
+		return types.NewPointer(typ)
+	}
+
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		if obj, _ := types.Universe.Lookup(name).(*types.TypeName); obj != nil {
+			return obj.Type()
+		}
+		if pkg == nil {
+			return nil
+		}
+		if obj, _ := pkg.Scope().Lookup(name).(*types.TypeName); obj != nil {
+			return obj.Type()
+		}
+		return nil
+	}
+
+	p := findPackage(pkg, name[:i], map[*types.Package]bool{})
+	if p == nil {
+		return nil
+	}
+	if obj, _ := p.Scope().Lookup(name[i+1:]).(*types.TypeName); obj != nil {
+		return obj.Type()
+	}
+	return nil
+}
+
+func findPackage(pkg *types.Package, path string, seen map[*types.Package]bool) *types.Package {
+	if pkg == nil || seen[pkg] {
+		return nil
+	}
+	seen[pkg] = true
+
+	if pkg.Path() == path {
+		return pkg
+	}
+
+	for _, p := range pkg.Imports() {
+		if found := findPackage(p, path, seen); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+// funcCall is a call of a function which is not supported by xpath package.
+// Before compiling, a call is replaced with an attribute "@prefix:name.N"
+// which is evaluated by NodeNavigator when its value is requested.
+// The attribute is converted to the kind of the function such as "number(@go:line.0)".
+type funcCall struct {
+	prefix string
+	name   string
+	index  int         // index in the calls of the query
+	id     string      // local name of the attribute
+	def    *funcDef    // nil if the function is not in the library
+	args   []string    // arguments whose calls are replaced with placeholders
+	nested []*funcCall // calls in the arguments
+}
+
+// lookup returns the definition of the function.
+// A function which is not in the library is found from the registered functions.
+func (c *funcCall) lookup(funcs map[string]*funcDef) *funcDef {
+	if c.def != nil {
+		return c.def
+	}
+	return funcs[c.prefix+":"+c.name]
+}
+
+// attr evaluates the call for the node.
+// Each argument is evaluated by its own xpath.Expr from the compiled query of the navigator.
+func (c *funcCall) attr(n *NodeNavigator, node ast.Node) attr {
+	exprs := n.query.args[c]
+	args := make([]interface{}, len(exprs))
+	for i := range exprs {
+		nav := n.Copy().(*NodeNavigator)
+		nav.attr = -1
+		nav.calls = c.nested
+		switch v := exprs[i].Evaluate(nav).(type) {
+		case *xpath.NodeIterator:
+			args[i] = newResult(n.fset, v)
+		default:
+			args[i] = v
+		}
+	}
+
+	typesPkg, info := n.typesOf(node)
+	ctx := &FuncContext{
+		Node:      node,
+		Fset:      n.fset,
+		Pkg:       typesPkg,
+		TypesInfo: info,
+	}
+	if _, ok := node.(*pkg); ok {
+		ctx.Node = nil
+	}
+
+	def := c.lookup(n.funcs)
+	return attr{
+		parent: node,
+		prefix: c.prefix,
+		name:   c.id,
+		val:    def.kind.value(def.fn(ctx, args...)),
+	}
+}
+
+// compileCalls replaces calls of functions which have a prefix such as "go:line()"
+// to placeholders which are resolved by resolveCalls.
+func compileCalls(expr string, calls *[]*funcCall) (string, error) {
+	var (
+		b     strings.Builder
+		quote rune
+	)
+
+	for i := 0; i < len(expr); i++ {
+		c := rune(expr[i])
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == 0:
+			return "", fmt.Errorf("invalid character NUL in %q", expr)
+		case isNameStart(c) && (i == 0 || !isNameChar(rune(expr[i-1]))):
+			prefix, name, args, end, ok := scanCall(expr, i)
+			if !ok {
+				break
+			}
+
+			call, err := newFuncCall(prefix, name, args, calls)
+			if err != nil {
+				return "", err
+			}
+			call.index = len(*calls)
+			call.id = fmt.Sprintf("%s.%d", name, call.index)
+			*calls = append(*calls, call)
+
+			fmt.Fprintf(&b, "\x00%d\x00", call.index)
+			i = end
+			continue
+		}
+		b.WriteByte(expr[i])
+	}
+
+	if quote != 0 {
+		return "", fmt.Errorf("unclosed string literal in %q", expr)
+	}
+
+	return b.String(), nil
+}
+
+// newFuncCall creates a funcCall.
+// Functions which are not prefixed by "go" are resolved in evaluation
+// because they are registered to an Evaluator.
+func newFuncCall(prefix, name string, args []string, calls *[]*funcCall) (*funcCall, error) {
+	var def *funcDef
+	if prefix == "go" {
+		def = goFuncs[name]
+		if def == nil {
+			return nil, fmt.Errorf("unknown function %s:%s()", prefix, name)
+		}
+
+		if len(args) < def.minArgs || len(args) > def.maxArgs {
+			return nil, fmt.Errorf("%s:%s() has wrong number of arguments: %d", prefix, name, len(args))
+		}
+	}
+
+	call := &funcCall{
+		prefix: prefix,
+		name:   name,
+		def:    def,
+		args:   make([]string, len(args)),
+	}
+
+	nested := len(*calls)
+	for i := range args {
+		arg, err := compileCalls(args[i], calls)
+		if err != nil {
+			return nil, err
+		}
+		call.args[i] = arg
+	}
+
+	call.nested = make([]*funcCall, len(*calls)-nested)
+	copy(call.nested, (*calls)[nested:])
+
+	return call, nil
+}
+
+// resolveCalls replaces placeholders of the calls with expressions which refer to their attributes
+// with the kinds of the functions.
+// Attributes which are selected by a wildcard such as "@*" are filtered
+// because the attributes of the calls must be hidden.
+func resolveCalls(expr string, calls []*funcCall, kinds []Kind) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(expr, 0)
+		if i < 0 {
+			b.WriteString(expr)
+			break
+		}
+		j := i + 1 + strings.IndexByte(expr[i+1:], 0)
+		index, _ := strconv.Atoi(expr[i+1 : j])
+		c := calls[index]
+		b.WriteString(expr[:i])
+		b.WriteString(kinds[index].expr("@" + c.prefix + ":" + c.id))
+		expr = expr[j+1:]
+	}
+
+	if len(calls) == 0 {
+		return b.String()
+	}
+	return hideCallAttrs(b.String())
+}
+
+// hideCallAttrs adds a predicate which excludes attributes with a prefix
+// to attribute steps with a wildcard such as "@*" and "attribute::node()".
+// Attributes of nodes never have a prefix but the attributes of the calls always have a prefix.
+func hideCallAttrs(expr string) string {
+	var (
+		b     strings.Builder
+		quote byte
+	)
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '@':
+			if end, ok := scanAttrWildcard(expr, i+1); ok {
+				b.WriteString(expr[i:end])
+				b.WriteString("[not(contains(name(), ':'))]")
+				i = end - 1
+				continue
+			}
+		case strings.HasPrefix(expr[i:], "attribute") && (i == 0 || !isNameChar(rune(expr[i-1]))):
+			j := skipSpaces(expr, i+len("attribute"))
+			if !strings.HasPrefix(expr[j:], "::") {
+				break
+			}
+			if end, ok := scanAttrWildcard(expr, j+2); ok {
+				b.WriteString(expr[i:end])
+				b.WriteString("[not(contains(name(), ':'))]")
+				i = end - 1
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+// scanAttrWildcard scans "*" or "node()" which starts at the i-th byte of the expr after spaces.
+func scanAttrWildcard(expr string, i int) (end int, ok bool) {
+	i = skipSpaces(expr, i)
+	switch {
+	case strings.HasPrefix(expr[i:], "*"):
+		return i + 1, true
+	case strings.HasPrefix(expr[i:], "node"):
+		j := skipSpaces(expr, i+len("node"))
+		if !strings.HasPrefix(expr[j:], "(") {
+			return 0, false
+		}
+		j = skipSpaces(expr, j+1)
+		if !strings.HasPrefix(expr[j:], ")") {
+			return 0, false
+		}
+		return j + 1, true
+	}
+	return 0, false
+}
+
+func skipSpaces(expr string, i int) int {
+	for i < len(expr) && isSpace(expr[i]) {
+		i++
+	}
+	return i
+}
+
+func (n *NodeNavigator) registerFunc(name string, kind Kind, fn Func) {
+	prefix, _, ok := splitFuncName(name)
+	if !ok {
+		panic(fmt.Sprintf("astquery: invalid function name %q", name))
+	}
+
+	if prefix == "go" {
+		panic(fmt.Sprintf("astquery: prefix go is reserved: %q", name))
+	}
+
+	switch kind {
+	case KindBool, KindNumber, KindString:
+	default:
+		panic(fmt.Sprintf("astquery: invalid kind %v of %q", kind, name))
+	}
+
+	if n.funcs == nil {
+		n.funcs = make(map[string]*funcDef)
+	}
+	n.funcs[name] = &funcDef{fn: fn, kind: kind, minArgs: 0, maxArgs: -1}
+}
+
+// splitFuncName splits a qualified function name such as "my:func" into the prefix and the local name.
+func splitFuncName(name string) (prefix, local string, ok bool) {
+	i := strings.Index(name, ":")
+	if i <= 0 || i == len(name)-1 {
+		return "", "", false
+	}
+
+	prefix, local = name[:i], name[i+1:]
+	if scanName(prefix, 0) != len(prefix) || !isNameStart(rune(prefix[0])) ||
+		scanName(local, 0) != len(local) || !isNameStart(rune(local[0])) {
+		return "", "", false
+	}
+
+	return prefix, local, true
+}
+
+// scanCall scans a call of a prefixed function which starts at the i-th byte of the expr.
+// It returns the index of the closing parenthesis as end.
+func scanCall(expr string, i int) (prefix, name string, args []string, end int, ok bool) {
+	j := scanName(expr, i)
+	if j >= len(expr) || expr[j] != ':' {
+		return "", "", nil, 0, false
+	}
+	prefix = expr[i:j]
+
+	if j+1 >= len(expr) || !isNameStart(rune(expr[j+1])) {
+		// such as "child::"
+		return "", "", nil, 0, false
+	}
+	k := scanName(expr, j+1)
+	name = expr[j+1 : k]
+
+	for k < len(expr) && isSpace(expr[k]) {
+		k++
+	}
+	if k >= len(expr) || expr[k] != '(' {
+		return "", "", nil, 0, false
+	}
+
+	var (
+		depth int
+		quote byte
+		start = k + 1
+	)
+	for k++; k < len(expr); k++ {
+		c := expr[k]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case (c == ')' || c == ']') && depth > 0:
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, expr[start:k])
+			start = k + 1
+		case c == ')':
+			if arg := expr[start:k]; len(args) != 0 || strings.TrimSpace(arg) != "" {
+				args = append(args, arg)
+			}
+			return prefix, name, args, k, true
+		}
+	}
+
+	return "", "", nil, 0, false
+}
+
+func scanName(expr string, i int) int {
+	for i < len(expr) && isNameChar(rune(expr[i])) {
+		i++
+	}
+	return i
+}
+
+func isNameStart(c rune) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isNameChar(c rune) bool {
+	return isNameStart(c) || c == '-' || c == '.' || '0' <= c && c <= '9'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
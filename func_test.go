@@ -0,0 +1,214 @@
+package astquery_test
+
+import (
+	"fmt"
+	"go/ast"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestFunc(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestFunc", f) }
+	cases := map[string]struct {
+		path  string
+		xpath string
+		want  interface{}
+	}{
+		"exported":     {TD("funcs.go"), "//*[@type='FuncDecl' and go:exported(Name/@Name)]/Name/@Name", []interface{}{"Read", "Exported", "TestA"}},
+		"exported-ctx": {TD("funcs.go"), "//*[@type='FuncDecl' and not(go:exported())]/Name/@Name", []interface{}{"unexported"}},
+		"line":         {TD("funcs.go"), "//*[@type='FuncDecl' and go:line() > 10]/Name/@Name", []interface{}{"Exported", "unexported"}},
+		"file":         {TD("funcs.go"), "//*[@type='FuncDecl' and go:file() = 'a_test.go']/Name/@Name", []interface{}{"TestA"}},
+		"is-test-file": {TD("funcs.go"), "//*[@type='FuncDecl' and go:is-test-file()]/Name/@Name", []interface{}{"TestA"}},
+		"implements":   {TD("funcs.go"), "//*[@type='ValueSpec']/Names[go:implements(@typeof, 'io.Reader')]/@Name", []interface{}{"r"}},
+		"nested":       {TD("funcs.go"), "count(//*[@type='FuncDecl' and go:exported(concat('X', go:line()))])", float64(4)},
+		"string":       {TD("funcs.go"), "//*[@type='FuncDecl' and go:file() = 'go:file()']", []interface{}{}},
+		"hidden":       {TD("funcs.go"), "count(//*[go:exported()]/@*[contains(name(), ':')])", float64(0)},
+		"false-string": {TD("funcs.go"), "//*[@type='FuncDecl' and string(go:exported()) = 'false']/Name/@Name", []interface{}{"unexported"}},
+		"not":          {TD("funcs.go"), "//*[@type='FuncDecl' and not(go:exported())]/Name/@Name", []interface{}{"unexported"}},
+		"root-number":  {TD("funcs.go"), "string(go:line())", "NaN"},
+		"root":         {TD("funcs.go"), "string(go:is-test-file())", "false"},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			got, err := e.Eval(tt.xpath)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEvaluator_RegisterFunc(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestFunc", f) }
+	deprecated := func(ctx *astquery.FuncContext, args ...interface{}) interface{} {
+		id, ok := ctx.Node.(*ast.Ident)
+		return ok && id.Name == "unexported"
+	}
+	concat := func(ctx *astquery.FuncContext, args ...interface{}) interface{} {
+		var s string
+		for _, arg := range args {
+			switch arg := arg.(type) {
+			case *astquery.Result:
+				for _, a := range arg.Attributes() {
+					s += a.Value
+				}
+			default:
+				s += fmt.Sprint(arg)
+			}
+		}
+		return s
+	}
+
+	// name is called only for identifiers which are selected by the preceding predicate
+	name := func(ctx *astquery.FuncContext, args ...interface{}) interface{} {
+		return ctx.Node.(*ast.Ident).Name
+	}
+
+	cases := map[string]struct {
+		path    string
+		xpath   string
+		want    interface{}
+		wantErr bool
+	}{
+		"node":    {TD("funcs.go"), "//*[my:deprecated()]/@Name", []interface{}{"unexported"}, false},
+		"args":    {TD("funcs.go"), "//*[@type='FuncDecl' and my:concat(Name/@Name, '!', 1) = 'Exported!1']/Name/@Name", []interface{}{"Exported"}, false},
+		"unknown": {TD("funcs.go"), "//*[my:unknown()]", nil, true},
+		"false":   {TD("funcs.go"), "string(my:deprecated())", "false", false},
+		"root":    {TD("funcs.go"), "my:answer() + 1", float64(43), false},
+		"typed":   {TD("funcs.go"), "//*[@type='Ident'][my:name() = 'Read']/@Name", []interface{}{"Read"}, false},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			e.RegisterFunc("my:deprecated", astquery.KindBool, deprecated)
+			e.RegisterFunc("my:concat", astquery.KindString, concat)
+			e.RegisterFunc("my:answer", astquery.KindNumber, func(*astquery.FuncContext, ...interface{}) interface{} { return float64(42) })
+			e.RegisterFunc("my:name", astquery.KindString, name)
+			got, err := e.Eval(tt.xpath)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error does not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// TestEvaluator_RegisterFunc_Calls counts calls of a function behind a selective predicate.
+// The function must be called only for the nodes which satisfy the preceding predicate.
+func TestEvaluator_RegisterFunc_Calls(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		xpath     string
+		want      interface{}
+		wantCalls int
+	}{
+		"name": {"count(//*[@Name='R'][my:count()])", float64(3), 3},
+		"type": {"count(//*[@type='Ident'][my:count()])", float64(23), 23},
+		"root": {"count(/*[@type='Ident'][my:count()])", float64(0), 0},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			var calls int
+			e := newEvaluator(t, filepath.Join("testdata", "TestFunc", "funcs.go"))
+			e.RegisterFunc("my:count", astquery.KindBool, func(*astquery.FuncContext, ...interface{}) interface{} {
+				calls++
+				return true
+			})
+
+			got, err := e.Eval(tt.xpath)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("want %d calls but got %d", tt.wantCalls, calls)
+			}
+		})
+	}
+}
+
+func TestEvaluator_RegisterFunc_InvalidName(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		name string
+		kind astquery.Kind
+	}{
+		"noprefix": {"deprecated", astquery.KindBool},
+		"reserved": {"go:deprecated", astquery.KindBool},
+		"empty":    {"my:", astquery.KindBool},
+		"nodeset":  {"my:nodes", astquery.KindNodeSet},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			defer func() {
+				if recover() == nil {
+					t.Error("expected panic does not occur")
+				}
+			}()
+			e := newEvaluator(t, filepath.Join("testdata", "TestFunc", "funcs.go"))
+			e.RegisterFunc(tt.name, tt.kind, func(*astquery.FuncContext, ...interface{}) interface{} { return nil })
+		})
+	}
+}
+
+// TestFunc_Concurrent evaluates a query which has function calls from goroutines.
+// It should be run with -race.
+func TestFunc_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	// a boolean operator in an argument selects nodes by the shared argument without cloning it
+	q := astquery.MustCompile("count(//*[@type='FuncDecl' and go:exported(concat(Name/@Name, Name and Type))])")
+	e := newEvaluator(t, filepath.Join("testdata", "TestFunc", "funcs.go"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				v, err := e.EvalQuery(q)
+				if err != nil {
+					t.Error("unexpected error:", err)
+					return
+				}
+				if v != float64(3) {
+					t.Errorf("want 3 but got %v", v)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
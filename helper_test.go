@@ -3,12 +3,16 @@ package astquery_test
 import (
 	"fmt"
 	"go/ast"
+	"go/importer"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/packages"
 	"golang.org/x/tools/txtar"
 )
 
@@ -43,11 +47,47 @@ func newInspector(t *testing.T, stmtStr string) (ast.Stmt, *astquery.Inspector)
 	return stmt, in
 }
 
-func newEvaluator(t *testing.T, path string) *astquery.Evaluator {
+func newEvaluator(t *testing.T, path string, opts ...astquery.Option) *astquery.Evaluator {
 	t.Helper()
 	fset := token.NewFileSet()
 	files := parse(t, fset, path)
-	return astquery.New(fset, files, nil)
+	pkg, info := typecheck(t, fset, "a", files)
+	opts = append([]astquery.Option{astquery.WithTypesInfo(pkg, info)}, opts...)
+	return astquery.New(fset, files, nil, opts...)
+}
+
+func newPackage(t *testing.T, fset *token.FileSet, path, pkgPath string) *packages.Package {
+	t.Helper()
+	files := parse(t, fset, path)
+	pkg, info := typecheck(t, fset, pkgPath, files)
+	return &packages.Package{
+		ID:        pkgPath,
+		Name:      files[0].Name.Name,
+		PkgPath:   pkgPath,
+		Fset:      fset,
+		Syntax:    files,
+		Types:     pkg,
+		TypesInfo: info,
+		Module:    &packages.Module{Path: "example.com"},
+	}
+}
+
+func typecheck(t *testing.T, fset *token.FileSet, pkgPath string, files []*ast.File) (*types.Package, *types.Info) {
+	t.Helper()
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Defs:       map[*ast.Ident]types.Object{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Implicits:  map[ast.Node]types.Object{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+		Scopes:     map[ast.Node]*types.Scope{},
+	}
+	config := &types.Config{
+		Importer: importer.Default(),
+		Error:    func(error) {}, // ignore type errors
+	}
+	pkg, _ := config.Check(pkgPath, fset, files, info)
+	return pkg, info
 }
 
 func parse(t *testing.T, fset *token.FileSet, path string) []*ast.File {
@@ -60,7 +100,7 @@ func parse(t *testing.T, fset *token.FileSet, path string) []*ast.File {
 	files := make([]*ast.File, len(ar.Files))
 	for i := range ar.Files {
 		n, d := ar.Files[i].Name, ar.Files[i].Data
-		f, err := parser.ParseFile(fset, n, d, 0)
+		f, err := parser.ParseFile(fset, n, d, parser.ParseComments)
 		if err != nil {
 			t.Fatal("unexpected error:", err)
 		}
@@ -69,3 +109,11 @@ func parse(t *testing.T, fset *token.FileSet, path string) []*ast.File {
 
 	return files
 }
+
+// newFields matches attributes of fields which are added to go/ast after Go 1.15 such as BasicLit.ValueEnd.
+// Their existence depends on the toolchain, so they are removed from outputs which are compared with golden files.
+var newFields = regexp.MustCompile(` (ValueEnd|FileStart|FileEnd|GoVersion|Range)="[^"]*"`)
+
+func trimNewFields(s string) string {
+	return newFields.ReplaceAllString(s, "")
+}
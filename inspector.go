@@ -2,12 +2,16 @@ package astquery
 
 import (
 	"go/ast"
+	"go/token"
+	"sync"
 
 	"golang.org/x/tools/go/ast/astutil"
 	"golang.org/x/tools/go/ast/inspector"
 )
 
 // Inspector is wrapper of *golang.org/x/go/ast/inspector.Inspector.
+// Its methods traverse the files for each call.
+// NodeNavigator does not use them but an index which is built at the first navigation.
 type Inspector struct {
 	*inspector.Inspector
 }
@@ -19,6 +23,11 @@ func NewInspector(files []*ast.File) *Inspector {
 
 // Children returns children of the given node.
 func (in *Inspector) Children(n ast.Node) []ast.Node {
+	return childrenOf(n)
+}
+
+// childrenOf returns children of the node by ast.Inspect.
+func childrenOf(n ast.Node) []ast.Node {
 	if n == nil {
 		return nil
 	}
@@ -31,9 +40,6 @@ func (in *Inspector) Children(n ast.Node) []ast.Node {
 		}
 		return true
 	})
-	if len(children) != 0 {
-		return children
-	}
 	return children
 }
 
@@ -59,17 +65,20 @@ func (in *Inspector) Stack(n ast.Node) []ast.Node {
 // Path returns a path to the given node from the root node.
 // The path's first element is the given node and last element is the root node.
 func (in *Inspector) Path(n ast.Node) []ast.Node {
-	stack := in.Stack(n)
-	if stack == nil {
+	return reverse(in.Stack(n))
+}
+
+func reverse(ns []ast.Node) []ast.Node {
+	if ns == nil {
 		return nil
 	}
 
-	path := make([]ast.Node, len(stack))
-	for i := range path {
-		path[i] = stack[len(stack)-1-i]
+	reversed := make([]ast.Node, len(ns))
+	for i := range reversed {
+		reversed[i] = ns[len(ns)-1-i]
 	}
 
-	return path
+	return reversed
 }
 
 // Parent returns a parent node of the given node.
@@ -97,6 +106,8 @@ func (in *Inspector) Name(n ast.Node) string {
 }
 
 // Index returns parent's field index.
+// If the given node is not an element of a slice field, Index returns -1.
+// If the given node is not found, Index returns 0.
 func (in *Inspector) Index(n ast.Node) int {
 	var index int
 	parent := in.Parent(n)
@@ -110,3 +121,189 @@ func (in *Inspector) Index(n ast.Node) int {
 
 	return index
 }
+
+// nodeIndex is an index of the files which maps each node to its parent, field name and children.
+// It is built at the first lookup.
+// If fset is not nil, comment groups in ast.File.Comments are associated with nodes
+// and the groups which are not reachable from fields become children named "Comments".
+type nodeIndex struct {
+	fset  *token.FileSet
+	files []*ast.File
+	once  sync.Once
+	m     map[ast.Node]*nodeInfo
+}
+
+// nodeInfo holds a relation between a node and its parent.
+type nodeInfo struct {
+	parent   ast.Node
+	name     string
+	index    int
+	sibling  int // index in the parent's children
+	children []ast.Node
+	comments []*ast.CommentGroup // associated by ast.CommentMap
+}
+
+func newNodeIndex(fset *token.FileSet, files []*ast.File) *nodeIndex {
+	return &nodeIndex{fset: fset, files: files}
+}
+
+func (idx *nodeIndex) build() {
+	idx.m = make(map[ast.Node]*nodeInfo)
+	for _, f := range idx.files {
+		idx.m[f] = &nodeInfo{index: -1}
+		astutil.Apply(f, func(cur *astutil.Cursor) bool {
+			n := cur.Node()
+			if n == nil || n == f {
+				return true
+			}
+
+			parent := idx.m[cur.Parent()]
+			idx.m[n] = &nodeInfo{
+				parent:  cur.Parent(),
+				name:    cur.Name(),
+				index:   cur.Index(),
+				sibling: len(parent.children),
+			}
+			parent.children = append(parent.children, n)
+
+			return true
+		}, nil)
+
+		if idx.fset != nil {
+			idx.indexComments(f)
+		}
+	}
+}
+
+func (idx *nodeIndex) indexComments(f *ast.File) {
+	cmap := ast.NewCommentMap(idx.fset, f, f.Comments)
+	for n, cgs := range cmap {
+		info := idx.m[n]
+		if info == nil {
+			continue
+		}
+		info.comments = cgs
+
+		var index int
+		for _, cg := range cgs {
+			if idx.m[cg] != nil {
+				// already reachable such as ast.FuncDecl.Doc
+				continue
+			}
+
+			idx.m[cg] = &nodeInfo{
+				parent: n,
+				name:   "Comments",
+				index:  index,
+			}
+			info.children = insertByPos(info.children, cg)
+			index++
+
+			cginfo := idx.m[cg]
+			for i, c := range cg.List {
+				idx.m[c] = &nodeInfo{
+					parent:  cg,
+					name:    "List",
+					index:   i,
+					sibling: i,
+				}
+				cginfo.children = append(cginfo.children, c)
+			}
+		}
+
+		for i, child := range info.children {
+			idx.m[child].sibling = i
+		}
+	}
+}
+
+// insertByPos inserts the node before the first node which is placed after it.
+// The nodes are in the order of fields which is not always the source order
+// such as ast.FuncDecl.Type placed before Recv, so they are scanned linearly.
+func insertByPos(ns []ast.Node, n ast.Node) []ast.Node {
+	i := len(ns)
+	for j, m := range ns {
+		if m.Pos() > n.Pos() {
+			i = j
+			break
+		}
+	}
+	ns = append(ns, nil)
+	copy(ns[i+1:], ns[i:])
+	ns[i] = n
+	return ns
+}
+
+func (idx *nodeIndex) info(n ast.Node) *nodeInfo {
+	idx.once.Do(idx.build)
+	return idx.m[n]
+}
+
+// children returns children of the node.
+// If the node is not in the files, they are found by ast.Inspect.
+func (idx *nodeIndex) children(n ast.Node) []ast.Node {
+	if info := idx.info(n); info != nil {
+		return info.children
+	}
+	return childrenOf(n)
+}
+
+// stack returns nodes between the file and the node.
+// If the node is not in the files, it returns nil.
+func (idx *nodeIndex) stack(n ast.Node) []ast.Node {
+	if idx.info(n) == nil {
+		return nil
+	}
+
+	var path []ast.Node
+	for ; n != nil; n = idx.parent(n) {
+		path = append(path, n)
+	}
+
+	return reverse(path)
+}
+
+func (idx *nodeIndex) parent(n ast.Node) ast.Node {
+	info := idx.info(n)
+	if info == nil {
+		return nil
+	}
+	return info.parent
+}
+
+func (idx *nodeIndex) name(n ast.Node) string {
+	info := idx.info(n)
+	if info == nil {
+		return ""
+	}
+	return info.name
+}
+
+// index returns the index of the node in the parent's slice field.
+// If the node is not an element of a slice field or is not found, it returns -1.
+func (idx *nodeIndex) index(n ast.Node) int {
+	info := idx.info(n)
+	if info == nil {
+		return -1
+	}
+	return info.index
+}
+
+// comments returns comment groups which are associated with the node by ast.CommentMap.
+func (idx *nodeIndex) comments(n ast.Node) []*ast.CommentGroup {
+	info := idx.info(n)
+	if info == nil {
+		return nil
+	}
+	return info.comments
+}
+
+// siblings returns children of the parent of the given node and
+// the index of the node in them.
+func (idx *nodeIndex) siblings(n ast.Node) ([]ast.Node, int) {
+	info := idx.info(n)
+	if info == nil || info.parent == nil {
+		return nil, 0
+	}
+	return idx.info(info.parent).children, info.sibling
+}
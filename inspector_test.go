@@ -1,9 +1,14 @@
 package astquery_test
 
 import (
+	"go/ast"
+	"go/parser"
+	"go/token"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/ast/inspector"
 )
 
 func TestInspector_Children(t *testing.T) {
@@ -152,3 +157,28 @@ func TestInspector_Index(t *testing.T) {
 		})
 	}
 }
+
+func TestInspector_Literal(t *testing.T) {
+	t.Parallel()
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "a.go", "package a\nfunc f() { _ = 10; return }", 0)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	stmt := f.Decls[0].(*ast.FuncDecl).Body.List[1]
+
+	// an Inspector which is not created by NewInspector
+	in := &astquery.Inspector{inspector.New([]*ast.File{f})}
+	if got := nodeType(t, in.Parent(stmt)); got != "BlockStmt" {
+		t.Errorf("Parent: want BlockStmt but got %s", got)
+	}
+	if got := in.Name(stmt); got != "List" {
+		t.Errorf("Name: want List but got %s", got)
+	}
+	if got := in.Index(stmt); got != 1 {
+		t.Errorf("Index: want 1 but got %d", got)
+	}
+	if got := in.Index(&ast.ReturnStmt{}); got != 0 {
+		t.Errorf("Index of a node which is not found: want 0 but got %d", got)
+	}
+}
@@ -0,0 +1,138 @@
+package astquery
+
+import (
+	"fmt"
+	"go/ast"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Message is a text/template of a message such as "call to {{.Name}} at {{.line}} is forbidden".
+// The template is executed with attributes of a node as a map[string]string
+// and a missing attribute is replaced with an empty string.
+// Only the attributes which are referred by the template such as {{.Name}} are computed.
+type Message struct {
+	src   string
+	tmpl  *template.Template
+	names map[string]bool // nil if the template refers the whole map such as {{index . "Name"}}
+}
+
+// ParseMessage parses a message template.
+func ParseMessage(s string) (*Message, error) {
+	tmpl, err := template.New("message").Option("missingkey=zero").Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("message cannot parse: %w", err)
+	}
+
+	names := make(map[string]bool)
+	if !referredNames(tmpl.Tree.Root, names) {
+		names = nil
+	}
+
+	return &Message{src: s, tmpl: tmpl, names: names}, nil
+}
+
+// referredNames collects names of the fields such as {{.Name}} which are referred from the node.
+// It reports false if the node refers the whole data or changes dot by with or range
+// because the referred attributes cannot be determined.
+func referredNames(node parse.Node, names map[string]bool) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *parse.ListNode:
+		if node == nil {
+			return true
+		}
+		for _, n := range node.Nodes {
+			if !referredNames(n, names) {
+				return false
+			}
+		}
+		return true
+	case *parse.ActionNode:
+		return referredNames(node.Pipe, names)
+	case *parse.IfNode:
+		return referredNames(node.Pipe, names) &&
+			referredNames(node.List, names) &&
+			referredNames(node.ElseList, names)
+	case *parse.PipeNode:
+		if node == nil {
+			return true
+		}
+		for _, cmd := range node.Cmds {
+			if !referredNames(cmd, names) {
+				return false
+			}
+		}
+		return true
+	case *parse.CommandNode:
+		for _, arg := range node.Args {
+			if !referredNames(arg, names) {
+				return false
+			}
+		}
+		return true
+	case *parse.FieldNode:
+		names[node.Ident[0]] = true
+		return true
+	case *parse.VariableNode:
+		// $.Name refers the data
+		if node.Ident[0] == "$" {
+			if len(node.Ident) == 1 {
+				return false
+			}
+			names[node.Ident[1]] = true
+		}
+		return true
+	case *parse.ChainNode:
+		return referredNames(node.Node, names)
+	case *parse.TextNode, *parse.IdentifierNode, *parse.BoolNode, *parse.NumberNode, *parse.StringNode, *parse.NilNode:
+		return true
+	}
+
+	// such as {{.}}, {{with}}, {{range}} and {{template}}
+	return false
+}
+
+// MustParseMessage is like ParseMessage but panics if the message cannot be parsed.
+func MustParseMessage(s string) *Message {
+	m, err := ParseMessage(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// String returns the source of the message template.
+func (m *Message) String() string {
+	return m.src
+}
+
+// Message returns the message for the node.
+func (e *Evaluator) Message(m *Message, n ast.Node) (string, error) {
+	data := make(map[string]string, len(m.names))
+	for _, a := range e.n.attributes(n) {
+		if a.call == nil && (m.names == nil || m.names[a.name]) {
+			data[a.name] = a.value()
+		}
+	}
+
+	var sb strings.Builder
+	if err := m.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("message cannot execute: %w", err)
+	}
+	return sb.String(), nil
+}
+
+// Attribute returns the value of the attribute of the node such as "src".
+// The name does not have "@".
+// If the node does not have the attribute, the second result is false.
+func (e *Evaluator) Attribute(n ast.Node, name string) (string, bool) {
+	for _, a := range e.n.attributes(n) {
+		if a.call == nil && a.name == name {
+			return a.value(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,70 @@
+package astquery_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestEvaluator_Message(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Message", f) }
+	cases := map[string]struct {
+		path    string
+		xpath   string
+		msg     string
+		want    []string
+		wantErr bool
+	}{
+		"name":    {TD("a.go"), "//*[@type='SelectorExpr']/Sel", "call to {{.Name}} at {{.line}}", S("call to Println at 7"), false},
+		"src":     {TD("a.go"), "//*[@type='CallExpr']", "{{.src}} ({{.typeof}})", S(`fmt.Println("hello") ((n int, err error))`), false},
+		"doc":     {TD("a.go"), "//*[@type='FuncDecl']", "{{.doc}}", S("f is a function."), false},
+		"missing": {TD("a.go"), "//*[@type='FuncDecl']", "[{{.nothing}}]", S("[]"), false},
+		"static":  {TD("a.go"), "//*[@type='FuncDecl']", "don't panic", S("don't panic"), false},
+		"invalid": {TD("a.go"), "//*[@type='FuncDecl']", "{{.Name", nil, true},
+		"if":      {TD("a.go"), "//*[@type='SelectorExpr']/Sel", "{{if .Name}}{{.Name}}{{else}}{{.nothing}}{{end}}", S("Println"), false},
+		"root":    {TD("a.go"), "//*[@type='SelectorExpr']/Sel", "{{$.Name}}", S("Println"), false},
+		"index":   {TD("a.go"), "//*[@type='SelectorExpr']/Sel", `{{index . "Name"}}`, S("Println"), false},
+		"with":    {TD("a.go"), "//*[@type='SelectorExpr']/Sel", "{{with .Name}}{{.}}{{end}}", S("Println"), false},
+		"range":   {TD("a.go"), "//*[@type='SelectorExpr']/Sel", "{{range $k, $v := .}}{{if eq $k \"Name\"}}{{$v}}{{end}}{{end}}", S("Println"), false},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			m, err := astquery.ParseMessage(tt.msg)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			e := newEvaluator(t, tt.path)
+			ns, err := e.Select(tt.xpath)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			var got []string
+			for _, n := range ns {
+				msg, err := e.Message(m, n)
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+				got = append(got, msg)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
@@ -1,31 +1,40 @@
 package astquery
 
 import (
-	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
 	"go/token"
+	"go/types"
 	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/antchfx/xpath"
 	"golang.org/x/tools/go/ast/inspector"
 )
 
+// pkg is the root node.
 type pkg struct {
 	files []*ast.File
+	pkgs  []*Package // if it is not nil, children of the root are packages
+
+	// packages which contain files
+	byFile      map[*ast.File]*Package
+	byTokenFile map[*token.File]*Package
 }
 
 var _ ast.Node = (*pkg)(nil)
 
 func (p *pkg) children() []ast.Node {
-	ns := make([]ast.Node, len(p.files))
-	for i := range p.files {
-		ns[i] = p.files[i]
+	if p.pkgs != nil {
+		ns := make([]ast.Node, len(p.pkgs))
+		for i := range p.pkgs {
+			ns[i] = p.pkgs[i]
+		}
+		return ns
 	}
-	return ns
+	return filesToNodes(p.files)
 }
 
 func (p *pkg) Pos() token.Pos {
@@ -42,9 +51,33 @@ func (p *pkg) End() token.Pos {
 	return p.files[len(p.files)-1].End()
 }
 
+// packageOf returns the package which contains the file.
+// If the root does not have packages, it returns nil.
+func (p *pkg) packageOf(f *ast.File) *Package {
+	return p.byFile[f]
+}
+
+func filesToNodes(files []*ast.File) []ast.Node {
+	ns := make([]ast.Node, len(files))
+	for i := range files {
+		ns[i] = files[i]
+	}
+	return ns
+}
+
 type attr struct {
 	parent    ast.Node
+	prefix    string
 	name, val string
+	nav       *NodeNavigator // if it is not nil, the value is computed lazily by the navigator
+	call      *funcCall      // if it is not nil, the value is computed by the call when it is requested
+}
+
+func (a attr) value() string {
+	if a.nav != nil {
+		return a.nav.attrValue(a.parent, a.name)
+	}
+	return a.val
 }
 
 func (a attr) Pos() token.Pos {
@@ -57,7 +90,7 @@ func (a attr) End() token.Pos {
 
 // NodeNavigator implements xpath.NodeNavigator.
 type NodeNavigator struct {
-	in       *Inspector
+	idx      *nodeIndex
 	fset     *token.FileSet
 	root     *pkg
 	node     ast.Node
@@ -65,26 +98,37 @@ type NodeNavigator struct {
 	index    int
 	attr     int
 	attrs    []attr
+	pkg      *types.Package
+	info     *types.Info
+	calls    []*funcCall
+	query    *compiledQuery // the query which is evaluated with the navigator
+	funcs    map[string]*funcDef
+	cache    *srcCache
+	// typeNames makes element names types of nodes instead of field names.
+	typeNames bool
 }
 
 var _ xpath.NodeNavigator = (*NodeNavigator)(nil)
 
 // NewNodeNavigator creates a NodeNavigator.
-// If in is not nil NodeNavigator use the given inspector.
-func NewNodeNavigator(fset *token.FileSet, files []*ast.File, in *inspector.Inspector) *NodeNavigator {
-
-	if in == nil {
-		in = inspector.New(files)
+// The navigator uses an index of the files which is built at the first navigation instead of the inspector.
+// in is kept for compatibility and can be nil.
+func NewNodeNavigator(fset *token.FileSet, files []*ast.File, in *inspector.Inspector, opts ...Option) *NodeNavigator {
+	root := &pkg{files: files}
+	n := &NodeNavigator{
+		idx:   newNodeIndex(fset, files),
+		fset:  fset,
+		node:  root,
+		root:  root,
+		attr:  -1,
+		cache: newSrcCache(),
 	}
 
-	root := &pkg{files: files}
-	return &NodeNavigator{
-		in:   &Inspector{in},
-		fset: fset,
-		node: root,
-		root: root,
-		attr: -1,
+	for _, opt := range opts {
+		opt(n)
 	}
+
+	return n
 }
 
 func (n *NodeNavigator) Node() ast.Node {
@@ -95,63 +139,90 @@ func (n *NodeNavigator) Node() ast.Node {
 }
 
 func (n *NodeNavigator) NodeType() xpath.NodeType {
+	if n.attr != -1 {
+		return xpath.AttributeNode
+	}
+
 	switch n.node.(type) {
 	case *pkg:
 		return xpath.RootNode
 	}
 
-	if n.attr == -1 {
-		return xpath.ElementNode
-	} else {
-		return xpath.AttributeNode
-	}
+	return xpath.ElementNode
 }
 
 func (n *NodeNavigator) LocalName() string {
+	if n.attr != -1 {
+		return n.attrs[n.attr].name
+	}
+
 	switch node := n.node.(type) {
 	case *pkg:
 		return ""
+	case *Package:
+		return "Package"
 	case *ast.File:
+		if n.typeNames {
+			return "File"
+		}
 		f := n.fset.File(node.Pos())
 		return filepath.Base(f.Name())
 	}
 
-	if n.attr != -1 {
-		return n.attrs[n.attr].name
+	if n.typeNames {
+		return typeName(n.node)
 	}
 
-	return n.in.Name(n.node)
+	return n.idx.name(n.node)
 }
 
 func (n *NodeNavigator) Prefix() string {
+	if n.attr != -1 {
+		return n.attrs[n.attr].prefix
+	}
 	return ""
 }
 
 func (n *NodeNavigator) Value() string {
+	if n.attr != -1 {
+		// evaluate a function call only when its own attribute is requested
+		// because the navigator passes it while looking for other attributes
+		if call := n.attrs[n.attr].call; call != nil {
+			n.attrs[n.attr] = call.attr(n, n.node)
+		}
+		return n.attrs[n.attr].value()
+	}
+
 	switch node := n.node.(type) {
 	case *pkg:
 		return ""
+	case *Package:
+		return node.PkgPath
 	case *ast.File:
 		f := n.fset.File(node.Pos())
 		return filepath.Base(f.Name())
 	}
 
-	if n.attr != -1 {
-		return n.attrs[n.attr].val
-	}
-
 	return fmt.Sprintf("%v", n.node)
 }
 
 func (n *NodeNavigator) Copy() xpath.NodeNavigator {
 	copied := &NodeNavigator{
-		in:    n.in,
+		idx:   n.idx,
 		fset:  n.fset,
 		root:  n.root,
 		node:  n.node,
 		index: n.index,
 		attr:  n.attr,
 		attrs: n.attrs,
+		pkg:   n.pkg,
+		info:  n.info,
+		calls: n.calls,
+		query: n.query,
+		funcs: n.funcs,
+		cache: n.cache,
+
+		typeNames: n.typeNames,
 	}
 	if n.siblings != nil {
 		copied.siblings = make([]ast.Node, len(n.siblings))
@@ -175,27 +246,35 @@ func (n *NodeNavigator) MoveToParent() bool {
 		return true
 	}
 
-	switch n.node.(type) {
+	var parent ast.Node
+	switch node := n.node.(type) {
 	case *pkg:
 		return false
+	case *Package:
+		n.MoveToRoot()
+		return true
+	case *ast.File:
+		if p := n.root.packageOf(node); p != nil {
+			parent = p
+		}
+	default:
+		parent = n.idx.parent(n.node)
 	}
 
-	parent := n.in.Parent(n.node)
 	if parent != nil {
 		debugf("^%T(from %T)>", parent, n.node)
 		n.node = parent
-		switch n.node.(type) {
+		switch node := n.node.(type) {
+		case *Package:
+			n.siblings, n.index = n.root.children(), indexOf(n.root.children(), node)
 		case *ast.File:
 			n.siblings = n.root.children()
-		default:
-			n.siblings = n.in.Children(n.in.Parent(n.node))
-		}
-		n.index = 0
-		for i := range n.siblings {
-			if n.siblings[i] == n.node {
-				n.index = i
-				break
+			if p := n.root.packageOf(node); p != nil {
+				n.siblings = filesToNodes(p.Syntax)
 			}
+			n.index = indexOf(n.siblings, node)
+		default:
+			n.siblings, n.index = n.idx.siblings(n.node)
 		}
 		debugf("%T %d %v\n", n.node, n.index, nodesToStr(n.siblings))
 		return true
@@ -205,15 +284,23 @@ func (n *NodeNavigator) MoveToParent() bool {
 	return true
 }
 
+func indexOf(ns []ast.Node, n ast.Node) int {
+	for i := range ns {
+		if ns[i] == n {
+			return i
+		}
+	}
+	return 0
+}
+
 func (n *NodeNavigator) MoveToNextAttribute() bool {
 	if n.attr == -1 {
-		n.attrs = attributes(n.fset, n.node)
+		n.attrs = n.attributes(n.node)
 	}
 
-	if n.attr >= len(n.attrs)-1 {
+	if n.attr+1 >= len(n.attrs) {
 		return false
 	}
-
 	n.attr++
 	return true
 }
@@ -223,18 +310,16 @@ func (n *NodeNavigator) MoveToChild() bool {
 		return false
 	}
 
+	var children []ast.Node
 	switch node := n.node.(type) {
 	case *pkg:
-		if len(node.files) == 0 {
-			return false
-		}
-		n.siblings = node.children()
-		n.index = 0
-		n.node = n.siblings[0]
-		return true
+		children = node.children()
+	case *Package:
+		children = filesToNodes(node.Syntax)
+	default:
+		children = n.idx.children(n.node)
 	}
 
-	children := n.in.Children(n.node)
 	debugf("%T[%v]>", n.node, nodesToStr(children))
 	if len(children) == 0 {
 		debugln("/")
@@ -282,7 +367,7 @@ func (n *NodeNavigator) MoveToPrevious() bool {
 func (n *NodeNavigator) MoveTo(to xpath.NodeNavigator) bool {
 	debugln("@")
 	_to, _ := to.(*NodeNavigator)
-	if _to == nil || n.in != _to.in {
+	if _to == nil || n.idx != _to.idx {
 		return false
 	}
 	n.node = _to.node
@@ -295,54 +380,188 @@ func (n *NodeNavigator) MoveTo(to xpath.NodeNavigator) bool {
 	return true
 }
 
-func attributes(fset *token.FileSet, n ast.Node) []attr {
-	switch n.(type) {
+// attributes returns attributes of the node.
+// Values of the attributes which are derived from the node itself, its comments and type information
+// such as @pos, @src and @typeof are computed lazily by attrValue.
+func (n *NodeNavigator) attributes(node ast.Node) []attr {
+	switch node := node.(type) {
 	case *pkg:
-		return nil
+		return n.callAttributes(node, nil)
+	case *Package:
+		return n.callAttributes(node, node.attributes())
 	}
 
-	rv := reflect.ValueOf(n)
-	if rv.Kind() == reflect.Ptr {
-		rv = rv.Elem()
+	names := attrNames(node)
+	attrs := make([]attr, 0, len(names)+len(n.calls))
+	for _, name := range names {
+		attrs = append(attrs, attr{parent: node, name: name, nav: n})
 	}
 
-	attrs := []attr{
-		{
-			parent: n,
-			name:   "type",
-			val:    strings.TrimPrefix(rv.Type().String(), "ast."),
-		},
-		{
-			parent: n,
-			name:   "pos",
-			val:    fset.Position(n.Pos()).String(),
-		},
+	if n.typeNames {
+		if field := n.idx.name(node); field != "" {
+			attrs = append(attrs, attr{parent: node, name: "field", val: field})
+		}
 	}
 
-	var src bytes.Buffer
-	if err := format.Node(&src, fset, n); err == nil {
+	attrs = append(attrs, n.commentAttributes(node)...)
+	attrs = append(attrs, n.typesAttributes(node)...)
+
+	return n.callAttributes(node, attrs)
+}
+
+// callAttributes appends attributes of the function calls in the query to attrs.
+func (n *NodeNavigator) callAttributes(node ast.Node, attrs []attr) []attr {
+	for _, call := range n.calls {
 		attrs = append(attrs, attr{
-			parent: n,
-			name:   "src",
-			val:    src.String(),
+			parent: node,
+			prefix: call.prefix,
+			name:   call.id,
+			call:   call,
 		})
 	}
+	return attrs
+}
+
+// attrValue computes a value of the attribute which is returned by attrNames,
+// commentAttributes or typesAttributes.
+func (n *NodeNavigator) attrValue(node ast.Node, name string) string {
+	switch name {
+	case "type":
+		return typeName(node)
+	case "pos":
+		return n.fset.Position(node.Pos()).String()
+	case "src":
+		return n.cache.src(n.fset, node)
+	case "doc", "comment":
+		return n.commentValue(node, name)
+	case "typeof", "obj", "objkind", "pkgpath":
+		return n.typesValue(node, name)
+	}
+
+	if v, ok := positionValue(n.fset, node, name); ok {
+		return v
+	}
+
+	rv := reflect.Indirect(reflect.ValueOf(node))
+	return n.fieldValue(rv.FieldByName(name).Interface())
+}
+
+// fieldValue returns a string representation of a field which is not a node.
+func (n *NodeNavigator) fieldValue(v interface{}) string {
+	if pos, ok := v.(token.Pos); ok {
+		return n.fset.Position(pos).String()
+	}
+	return scalarValue(v)
+}
+
+// scalarValue returns a string representation of a field which is neither a node nor a token.Pos.
+func scalarValue(v interface{}) string {
+	switch v := v.(type) {
+	case token.Token:
+		return v.String()
+	case ast.ChanDir:
+		switch v {
+		case ast.SEND:
+			return "send"
+		case ast.RECV:
+			return "recv"
+		case ast.SEND | ast.RECV:
+			return "both"
+		}
+	case []*ast.Ident:
+		names := make([]string, len(v))
+		for i := range v {
+			names[i] = v[i].Name
+		}
+		return strings.Join(names, ",")
+	}
+	return fmt.Sprintf("%v", v)
+}
 
-	if rv.Kind() == reflect.Struct {
-		for i := 0; i < rv.NumField(); i++ {
-			f := rv.Field(i)
-			switch f.Kind() {
+// typeName returns the name of the node's type without the package name such as "CallExpr".
+func typeName(node ast.Node) string {
+	return strings.TrimPrefix(reflect.Indirect(reflect.ValueOf(node)).Type().String(), "ast.")
+}
+
+var identsType = reflect.TypeOf([]*ast.Ident(nil))
+
+var attrNamesCache sync.Map // reflect.Type -> *typeAttrs
+
+// typeAttrs is names of the attributes which depend on only the type of a node.
+type typeAttrs struct {
+	names     []string
+	posFields map[string]int // token.Pos fields -> indexes of the fields
+}
+
+// attrNames returns names of the attributes which are derived from the node itself.
+// The names except position attributes and token.Pos fields depend on only the type of the node.
+// A token.Pos field which is not valid such as Ellipsis of a call without "..." is omitted.
+func attrNames(node ast.Node) []string {
+	typ := reflect.TypeOf(node)
+	v, ok := attrNamesCache.Load(typ)
+	if !ok {
+		v, _ = attrNamesCache.LoadOrStore(typ, newTypeAttrs(node))
+	}
+	ta := v.(*typeAttrs)
+
+	names := ta.names
+	if len(ta.posFields) != 0 {
+		rv := reflect.Indirect(reflect.ValueOf(node))
+		names = make([]string, 0, len(ta.names))
+		for _, name := range ta.names {
+			if i, ok := ta.posFields[name]; ok && !token.Pos(rv.Field(i).Int()).IsValid() {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+
+	if !node.Pos().IsValid() {
+		return names
+	}
+
+	// type, pos, position attributes and others
+	withPos := make([]string, 0, len(names)+len(positionNames))
+	withPos = append(withPos, names[:2]...)
+	withPos = append(withPos, positionNames...)
+	withPos = append(withPos, names[2:]...)
+
+	return withPos
+}
+
+func newTypeAttrs(node ast.Node) *typeAttrs {
+	ta := &typeAttrs{names: []string{"type", "pos"}}
+
+	if canFormat(node) {
+		ta.names = append(ta.names, "src")
+	}
+
+	rt := reflect.TypeOf(node)
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+
+	if rt.Kind() == reflect.Struct {
+		for i := 0; i < rt.NumField(); i++ {
+			ft := rt.Field(i).Type
+			switch ft.Kind() {
 			case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128, reflect.String, reflect.UnsafePointer:
-				attrs = append(attrs, attr{
-					parent: n,
-					name:   rv.Type().Field(i).Name,
-					val:    fmt.Sprintf("%v", rv.Field(i).Interface()),
-				})
+				ta.names = append(ta.names, rt.Field(i).Name)
+				if ft == posType {
+					if ta.posFields == nil {
+						ta.posFields = make(map[string]int)
+					}
+					ta.posFields[rt.Field(i).Name] = i
+				}
+			case reflect.Slice:
+				if ft == identsType {
+					ta.names = append(ta.names, rt.Field(i).Name)
+				}
 			}
 		}
 	}
 
-	return attrs
+	return ta
 }
 
 func nodes(iter *xpath.NodeIterator) []ast.Node {
@@ -352,9 +571,7 @@ func nodes(iter *xpath.NodeIterator) []ast.Node {
 		if current != nil && current.Node() != nil {
 			switch n := current.Node().(type) {
 			case *pkg:
-				for _, f := range n.files {
-					ns = append(ns, f)
-				}
+				ns = append(ns, n.children()...)
 			default:
 				ns = append(ns, n)
 			}
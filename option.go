@@ -0,0 +1,37 @@
+package astquery
+
+import (
+	"go/types"
+)
+
+// Option is an option for New and NewNodeNavigator.
+type Option func(*NodeNavigator)
+
+// WithTypesInfo makes a NodeNavigator provide type information as attributes.
+// The pkg may be nil.
+func WithTypesInfo(pkg *types.Package, info *types.Info) Option {
+	return func(n *NodeNavigator) {
+		n.pkg = pkg
+		n.info = info
+	}
+}
+
+// WithFunc registers a function which can be called in XPath expressions.
+// See Evaluator.RegisterFunc.
+func WithFunc(name string, kind Kind, fn Func) Option {
+	return func(n *NodeNavigator) {
+		n.registerFunc(name, kind, fn)
+	}
+}
+
+// WithTypeNames makes names of elements types of nodes such as "CallExpr" instead of field names of their parents.
+// A name of an *ast.File is "File".
+// The field name is provided as @field attribute.
+//
+// Example:
+//	//CallExpr/Ident[@field='Fun' and @Name='panic']
+func WithTypeNames() Option {
+	return func(n *NodeNavigator) {
+		n.typeNames = true
+	}
+}
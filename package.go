@@ -0,0 +1,111 @@
+package astquery
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Package is a node which represents a package.
+// It is a child of the root node of a NodeNavigator which is created by NewPackagesNodeNavigator.
+// Its children are files of the package.
+type Package struct {
+	*packages.Package
+}
+
+var _ ast.Node = (*Package)(nil)
+
+// Pos implements ast.Node.
+func (p *Package) Pos() token.Pos {
+	if len(p.Syntax) == 0 {
+		return token.NoPos
+	}
+	return p.Syntax[0].Pos()
+}
+
+// End implements ast.Node.
+func (p *Package) End() token.Pos {
+	if len(p.Syntax) == 0 {
+		return token.NoPos
+	}
+	return p.Syntax[len(p.Syntax)-1].End()
+}
+
+// String returns the path of the package.
+func (p *Package) String() string {
+	return p.PkgPath
+}
+
+func (p *Package) attributes() []attr {
+	attrs := []attr{
+		{parent: p, name: "type", val: "Package"},
+		{parent: p, name: "path", val: p.PkgPath},
+		{parent: p, name: "name", val: p.Name},
+	}
+
+	if p.Module != nil {
+		attrs = append(attrs, attr{parent: p, name: "module", val: p.Module.Path})
+	}
+
+	return attrs
+}
+
+// NewPackages creates an Evaluator for the packages.
+// The fset must be the token.FileSet which is used to load the packages such as packages.Config.Fset.
+// The root node of the tree has Package elements which have files of each package.
+// Type information of each package is used if it is loaded.
+func NewPackages(fset *token.FileSet, pkgs []*packages.Package, opts ...Option) *Evaluator {
+	return &Evaluator{n: NewPackagesNodeNavigator(fset, pkgs, opts...)}
+}
+
+// NewPackagesNodeNavigator creates a NodeNavigator for the packages.
+// See NewPackages.
+func NewPackagesNodeNavigator(fset *token.FileSet, pkgs []*packages.Package, opts ...Option) *NodeNavigator {
+	var files []*ast.File
+	root := &pkg{
+		pkgs:        make([]*Package, len(pkgs)),
+		byFile:      make(map[*ast.File]*Package),
+		byTokenFile: make(map[*token.File]*Package),
+	}
+	for i := range pkgs {
+		p := &Package{Package: pkgs[i]}
+		root.pkgs[i] = p
+		for _, f := range p.Syntax {
+			root.byFile[f] = p
+			root.byTokenFile[fset.File(f.Pos())] = p
+		}
+		files = append(files, p.Syntax...)
+	}
+	root.files = files
+
+	n := &NodeNavigator{
+		idx:   newNodeIndex(fset, files),
+		fset:  fset,
+		node:  root,
+		root:  root,
+		attr:  -1,
+		cache: newSrcCache(),
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// typesOf returns type information for the node.
+// If the navigator has packages, it returns information of the package which contains the node.
+func (n *NodeNavigator) typesOf(node ast.Node) (*types.Package, *types.Info) {
+	if n.root.pkgs == nil {
+		return n.pkg, n.info
+	}
+
+	if p := n.root.byTokenFile[n.fset.File(node.Pos())]; p != nil {
+		return p.Types, p.TypesInfo
+	}
+
+	return n.pkg, n.info
+}
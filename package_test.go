@@ -0,0 +1,63 @@
+package astquery_test
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestNewPackages(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestNewPackages", f) }
+	cases := map[string]struct {
+		xpath string
+		want  interface{}
+	}{
+		"path":   {"/Package/@path", []interface{}{"example.com/a", "example.com/b"}},
+		"name":   {"/Package[.//*[@type='FuncDecl' and Name/@Name='init']]/@name", []interface{}{"b"}},
+		"module": {"/Package/@module", []interface{}{"example.com", "example.com"}},
+		"files":  {"/Package[@name='b']/*/@file", []interface{}{"b.go", "b2.go"}},
+		"types":  {"//*[@type='FuncDecl']/Name/@obj", []interface{}{"example.com/a.F", "example.com/b.init"}},
+		"parent": {"//*[@type='ValueSpec']/ancestor::Package/@path", []interface{}{"example.com/b"}},
+		"count":  {"count(/Package/*)", float64(3)},
+		"value":  {"string(/Package[2])", "example.com/b"},
+	}
+
+	fset := token.NewFileSet()
+	pkgs := []*packages.Package{
+		newPackage(t, fset, TD("a.go"), "example.com/a"),
+		newPackage(t, fset, TD("b.go"), "example.com/b"),
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := astquery.NewPackages(fset, pkgs)
+			got, err := e.Eval(tt.xpath)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPackage_String(t *testing.T) {
+	t.Parallel()
+
+	fset := token.NewFileSet()
+	pkg := newPackage(t, fset, filepath.Join("testdata", "TestNewPackages", "a.go"), "example.com/a")
+	p := &astquery.Package{Package: pkg}
+	if got, want := fmt.Sprint(p), "example.com/a"; got != want {
+		t.Errorf("want %q but got %q", want, got)
+	}
+}
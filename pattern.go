@@ -0,0 +1,660 @@
+package astquery
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Pattern is a Go code snippet with metavariables which matches nodes structurally.
+// A pattern is an expression, a statement or a declaration and can contain the follows metavariables:
+//	$x   matches any node and binds it to x
+//	$*xs matches zero or more elements of a list such as arguments and binds them to xs
+//	$_   matches any node without binding, $*_ is the list version
+// The same metavariable must match structurally same nodes.
+// Positions are ignored except whether "..." of a call or "=" of an alias declaration exists.
+// Metavariables in string literals, rune literals and comments are not replaced.
+// A whole pattern cannot be a list metavariable such as $*xs.
+//
+// Example:
+//	fmt.Errorf($msg, $*args)
+//	if err != nil { return $*_ }
+type Pattern struct {
+	src   string
+	root  ast.Node
+	where map[string]*Query // metavariable -> constraint
+}
+
+// Match is a node which matches a pattern with bound metavariables.
+type Match struct {
+	Node  ast.Node
+	Nodes map[string]ast.Node   // bound by $x
+	Lists map[string][]ast.Node // bound by $*xs
+}
+
+const (
+	metaPrefix     = "__astquery_"
+	metaListPrefix = "__astquery_list_"
+	metaWildcard   = "_"
+)
+
+// ParsePattern parses a pattern.
+func ParsePattern(src string) (*Pattern, error) {
+	root, err := parsePattern(replaceMetaVars(src))
+	if err != nil {
+		return nil, fmt.Errorf("pattern cannot parse: %w", err)
+	}
+
+	if _, ok := metaList(root); ok {
+		return nil, errors.New("pattern cannot parse: a list metavariable cannot be a whole pattern")
+	}
+
+	return &Pattern{src: src, root: root, where: make(map[string]*Query)}, nil
+}
+
+// MustParsePattern is like ParsePattern but panics if the pattern cannot be parsed.
+func MustParsePattern(src string) *Pattern {
+	p, err := ParsePattern(src)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source of the pattern.
+func (p *Pattern) String() string {
+	return p.src
+}
+
+// Where constrains nodes bound to the metavariable by the XPath expression
+// which is evaluated with each bound node as the context node and converted to a boolean.
+// Attributes such as @typeof and functions registered by RegisterFunc can be used.
+// For a list metavariable $*xs, all the bound nodes must satisfy the constraint.
+// Where must be called before the pattern is used by Match.
+//
+// Example:
+//	p.Where("err", "@typeof='error'")
+func (p *Pattern) Where(name, expr string) error {
+	if name == metaWildcard || !p.hasMetaVar(name) {
+		return fmt.Errorf("pattern does not have a metavariable %s", name)
+	}
+
+	q, err := Compile("boolean(" + expr + ")")
+	if err != nil {
+		return fmt.Errorf("constraint of %s: %w", name, err)
+	}
+	p.where[name] = q
+
+	return nil
+}
+
+// hasMetaVar reports whether the pattern has the metavariable $name or $*name.
+func (p *Pattern) hasMetaVar(name string) bool {
+	var found bool
+	ast.Inspect(p.root, func(n ast.Node) bool {
+		if v, ok := metaVar(n); ok && v == name {
+			found = true
+		}
+		if v, ok := metaList(n); ok && v == name {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// replaceMetaVars replaces metavariables with identifiers which have metaPrefix or metaListPrefix.
+// Because the source is scanned as Go tokens, "$" in literals and comments is kept.
+func replaceMetaVars(src string) string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+
+	var sb strings.Builder
+	var last int
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.ILLEGAL || lit != "$" {
+			continue
+		}
+
+		start := file.Offset(pos)
+		prefix, end := metaPrefix, start+1
+		if strings.HasPrefix(src[end:], "*") {
+			prefix, end = metaListPrefix, end+1
+		}
+
+		nameStart := end
+		for end < len(src) && (isNameStart(rune(src[end])) || end > nameStart && '0' <= src[end] && src[end] <= '9') {
+			end++
+		}
+		if end == nameStart {
+			continue // a syntax error is reported by the parser
+		}
+
+		sb.WriteString(src[last:start])
+		sb.WriteString(prefix + src[nameStart:end])
+		last = end
+	}
+	sb.WriteString(src[last:])
+
+	return sb.String()
+}
+
+// parsePattern parses the code as an expression, a statement or a declaration.
+func parsePattern(code string) (ast.Node, error) {
+	if expr, err := parser.ParseExpr(code); err == nil {
+		return expr, nil
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", "package p; func _() {\n"+code+"\n}", 0)
+	if err == nil {
+		stmts := f.Decls[0].(*ast.FuncDecl).Body.List
+		if len(stmts) != 1 {
+			return nil, fmt.Errorf("pattern must be a single statement but got %d statements", len(stmts))
+		}
+		return stmts[0], nil
+	}
+
+	f, derr := parser.ParseFile(fset, "", "package p\n"+code, 0)
+	if derr != nil {
+		// the error as a statement is more helpful
+		return nil, err
+	}
+	if len(f.Decls) != 1 {
+		return nil, fmt.Errorf("pattern must be a single declaration but got %d declarations", len(f.Decls))
+	}
+
+	return f.Decls[0], nil
+}
+
+// Match returns nodes which match the pattern.
+// The pattern is compiled to an XPath expression which selects candidates by types, names, literals
+// and the numbers of list elements with the Evaluator's NodeNavigator,
+// then the candidates are matched structurally and metavariables are bound.
+// Package nodes never match.
+func (e *Evaluator) Match(p *Pattern) ([]*Match, error) {
+	ns, err := e.Select(p.xpath(e.n.typeNames))
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*Match
+	for _, n := range ns {
+		m := &matcher{
+			nodes: make(map[string]ast.Node),
+			lists: make(map[string][]ast.Node),
+			check: func(name string, n ast.Node) (bool, error) {
+				q := p.where[name]
+				if q == nil {
+					return true, nil
+				}
+				r, err := e.evaluateFrom(n, q)
+				if err != nil {
+					return false, fmt.Errorf("constraint of %s: %w", name, err)
+				}
+				return r.Bool(), nil
+			},
+		}
+		ok := m.match(reflect.ValueOf(p.root), reflect.ValueOf(n))
+		if m.err != nil {
+			return nil, m.err
+		}
+		if ok {
+			matches = append(matches, &Match{Node: n, Nodes: m.nodes, Lists: m.lists})
+		}
+	}
+
+	return matches, nil
+}
+
+// xpath returns an XPath expression which selects candidates of nodes which may match the pattern.
+// If typeNames is true, children are selected by @field instead of their element names.
+func (p *Pattern) xpath(typeNames bool) string {
+	return "//*" + (&patternCompiler{typeNames: typeNames}).preds(p.root)
+}
+
+// patternCompiler compiles a pattern to predicates of XPath.
+type patternCompiler struct {
+	typeNames bool
+}
+
+// preds returns predicates which constrain a node to match the pattern node such as "[@type='Ident'][@Name='err']".
+// Metavariables and equality of nodes bound to the same metavariable are not constrained.
+func (c *patternCompiler) preds(node ast.Node) string {
+	if _, ok := metaVar(node); ok {
+		return "[not(@type='Package')]"
+	}
+
+	// $x as a statement matches any statement
+	if stmt, ok := node.(*ast.ExprStmt); ok {
+		if _, ok := metaVar(stmt.X); ok {
+			return "[not(@type='Package')]"
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "[@type='%s']", typeName(node))
+
+	rv := reflect.Indirect(reflect.ValueOf(node))
+	for i := 0; i < rv.NumField(); i++ {
+		name, f := rv.Type().Field(i).Name, rv.Field(i)
+		switch f.Type() {
+		case posType:
+			// an invalid position is omitted from attributes
+			if validityPos(rv.Type(), name) {
+				if f.Interface().(token.Pos).IsValid() {
+					fmt.Fprintf(&sb, "[@%s]", name)
+				} else {
+					fmt.Fprintf(&sb, "[not(@%s)]", name)
+				}
+			}
+			continue
+		case objectType, scopeType, commentType:
+			continue
+		}
+
+		switch {
+		case f.Type().Implements(nodeType):
+			if f.IsNil() {
+				fmt.Fprintf(&sb, "[not(%s)]", c.elem(name))
+				continue
+			}
+			fmt.Fprintf(&sb, "[%s%s]", c.elem(name), c.child(f.Interface().(ast.Node)))
+		case f.Kind() == reflect.Slice && f.Type().Elem().Implements(nodeType):
+			sb.WriteString(c.list(name, f))
+		case f.Kind() == reflect.String, f.Kind() == reflect.Bool, f.Kind() == reflect.Int:
+			fmt.Fprintf(&sb, "[@%s=%s]", name, xpathString(scalarValue(f.Interface())))
+		}
+	}
+
+	return sb.String()
+}
+
+// child returns predicates of a child node.
+// Predicates for metavariables are omitted because a child is never a Package node.
+func (c *patternCompiler) child(node ast.Node) string {
+	if _, ok := metaVar(node); ok {
+		return ""
+	}
+	if stmt, ok := node.(*ast.ExprStmt); ok {
+		if _, ok := metaVar(stmt.X); ok {
+			return ""
+		}
+	}
+	return c.preds(node)
+}
+
+// list returns predicates of elements of a list field.
+// Elements before the first list metavariable and after the last one are constrained by their positions.
+func (c *patternCompiler) list(name string, f reflect.Value) string {
+	first, last := -1, -1
+	for i := 0; i < f.Len(); i++ {
+		if _, ok := metaList(f.Index(i).Interface()); ok {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	var sb strings.Builder
+	fixed := 0
+	for i := 0; i < f.Len(); i++ {
+		elem := f.Index(i).Interface().(ast.Node)
+		if _, ok := metaList(elem); ok {
+			continue
+		}
+		fixed++
+
+		preds := c.child(elem)
+		if preds == "" {
+			continue
+		}
+
+		switch {
+		case first < 0 || i < first:
+			fmt.Fprintf(&sb, "[%s%s]", c.elemAt(name, i, false), preds)
+		case i > last:
+			fmt.Fprintf(&sb, "[%s%s]", c.elemAt(name, f.Len()-1-i, true), preds)
+		}
+	}
+
+	switch {
+	case first < 0:
+		fmt.Fprintf(&sb, "[count(%s)=%d]", c.elem(name), fixed)
+	case fixed > 0:
+		fmt.Fprintf(&sb, "[count(%s)>=%d]", c.elem(name), fixed)
+	}
+
+	return sb.String()
+}
+
+// elem returns a step which selects children of the field.
+func (c *patternCompiler) elem(field string) string {
+	if c.typeNames {
+		return "*[@field='" + field + "']"
+	}
+	return field
+}
+
+// elemAt returns a step which selects the i-th child (0-origin) of the field.
+// If fromLast is true, i is counted from the last child.
+func (c *patternCompiler) elemAt(field string, i int, fromLast bool) string {
+	if c.typeNames {
+		// a positional predicate after [@field='F'] is not relative to the filtered children in the xpath package
+		axis := "preceding-sibling"
+		if fromLast {
+			axis = "following-sibling"
+		}
+		return fmt.Sprintf("%s[count(%s::%s)=%d]", c.elem(field), axis, c.elem(field), i)
+	}
+
+	if !fromLast {
+		return field + "[" + strconv.Itoa(i+1) + "]"
+	}
+	if i == 0 {
+		return field + "[last()]"
+	}
+	return field + "[last()-" + strconv.Itoa(i) + "]"
+}
+
+// xpathString returns a string literal of XPath.
+func xpathString(s string) string {
+	if !strings.Contains(s, "'") {
+		return "'" + s + "'"
+	}
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+
+	parts := strings.Split(s, "'")
+	for i := range parts {
+		parts[i] = "'" + parts[i] + "'"
+	}
+	return "concat(" + strings.Join(parts, `, "'", `) + ")"
+}
+
+// metaVar returns the name of the metavariable if the node is a metavariable $x.
+func metaVar(n interface{}) (string, bool) {
+	id, ok := n.(*ast.Ident)
+	if !ok || !strings.HasPrefix(id.Name, metaPrefix) || strings.HasPrefix(id.Name, metaListPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(id.Name, metaPrefix), true
+}
+
+// metaList returns the name of the metavariable if the node is a list metavariable $*xs.
+// A list metavariable can be an expression, an expression statement or a field without names.
+func metaList(n interface{}) (string, bool) {
+	switch n := n.(type) {
+	case *ast.Ident:
+		if strings.HasPrefix(n.Name, metaListPrefix) {
+			return strings.TrimPrefix(n.Name, metaListPrefix), true
+		}
+	case *ast.ExprStmt:
+		return metaList(n.X)
+	case *ast.Field:
+		if len(n.Names) == 0 && n.Tag == nil {
+			return metaList(n.Type)
+		}
+	}
+	return "", false
+}
+
+var (
+	posType     = reflect.TypeOf(token.NoPos)
+	objectType  = reflect.TypeOf((*ast.Object)(nil))
+	scopeType   = reflect.TypeOf((*ast.Scope)(nil))
+	commentType = reflect.TypeOf((*ast.CommentGroup)(nil))
+)
+
+// validityPos reports whether the validity of the token.Pos field of the struct type has a meaning.
+// For example, Ellipsis of CallExpr is valid only if the last argument is followed by "...".
+// Other positions are ignored in matching.
+func validityPos(t reflect.Type, field string) bool {
+	switch t {
+	case reflect.TypeOf(ast.CallExpr{}):
+		return field == "Ellipsis"
+	case reflect.TypeOf(ast.TypeSpec{}):
+		return field == "Assign" // alias declaration
+	}
+	return false
+}
+
+// matcher matches a pattern with a node and records bindings of metavariables.
+type matcher struct {
+	nodes map[string]ast.Node
+	lists map[string][]ast.Node
+	check func(name string, n ast.Node) (bool, error) // constraint of a metavariable, nil means no constraint
+	err   error                                       // the first error of check
+}
+
+func (m *matcher) match(p, n reflect.Value) bool {
+	if p.Kind() == reflect.Interface {
+		if p.IsNil() {
+			return n.Kind() == reflect.Interface && n.IsNil() || n.Kind() == reflect.Ptr && n.IsNil()
+		}
+		p = p.Elem()
+	}
+
+	if n.Kind() == reflect.Interface {
+		if n.IsNil() {
+			return p.Kind() == reflect.Ptr && p.IsNil()
+		}
+		n = n.Elem()
+	}
+
+	if p.Kind() == reflect.Ptr && p.IsNil() {
+		return n.Kind() == reflect.Ptr && n.IsNil()
+	}
+
+	if p.CanInterface() {
+		if name, ok := metaVar(p.Interface()); ok {
+			return m.bind(name, n)
+		}
+
+		// $x as a statement matches any statement
+		if stmt, ok := p.Interface().(*ast.ExprStmt); ok {
+			if name, ok := metaVar(stmt.X); ok {
+				if _, ok := n.Interface().(ast.Stmt); ok {
+					return m.bind(name, n)
+				}
+			}
+		}
+	}
+
+	if p.Type() != n.Type() {
+		return false
+	}
+
+	switch p.Kind() {
+	case reflect.Ptr:
+		if n.IsNil() {
+			return false
+		}
+		return m.match(p.Elem(), n.Elem())
+	case reflect.Struct:
+		for i := 0; i < p.NumField(); i++ {
+			switch p.Type().Field(i).Type {
+			case posType:
+				if validityPos(p.Type(), p.Type().Field(i).Name) &&
+					p.Field(i).Interface().(token.Pos).IsValid() != n.Field(i).Interface().(token.Pos).IsValid() {
+					return false
+				}
+				continue
+			case objectType, scopeType, commentType:
+				continue
+			}
+			if !m.match(p.Field(i), n.Field(i)) {
+				return false
+			}
+		}
+		return true
+	case reflect.Slice:
+		return m.matchList(p, 0, n, 0)
+	case reflect.String:
+		return p.String() == n.String()
+	case reflect.Bool:
+		return p.Bool() == n.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return p.Int() == n.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return p.Uint() == n.Uint()
+	case reflect.Float32, reflect.Float64:
+		return p.Float() == n.Float()
+	}
+
+	return false
+}
+
+// matchList matches p[i:] with n[j:]. A list metavariable matches zero or more elements.
+func (m *matcher) matchList(p reflect.Value, i int, n reflect.Value, j int) bool {
+	if i == p.Len() {
+		return j == n.Len()
+	}
+
+	elem := p.Index(i)
+	if name, ok := metaList(elem.Interface()); ok {
+		for k := n.Len(); k >= j; k-- {
+			saved := m.save()
+			if m.bindList(name, n.Slice(j, k)) && m.matchList(p, i+1, n, k) {
+				return true
+			}
+			m.restore(saved)
+		}
+		return false
+	}
+
+	if j == n.Len() {
+		return false
+	}
+
+	saved := m.save()
+	if m.match(elem, n.Index(j)) && m.matchList(p, i+1, n, j+1) {
+		return true
+	}
+	m.restore(saved)
+
+	return false
+}
+
+func (m *matcher) bind(name string, n reflect.Value) bool {
+	node, ok := n.Interface().(ast.Node)
+	if !ok || reflect.ValueOf(node).IsNil() {
+		return false
+	}
+
+	switch node.(type) {
+	case *pkg, *Package:
+		return false
+	}
+
+	if name == metaWildcard {
+		return true
+	}
+
+	if bound, ok := m.nodes[name]; ok {
+		return equalNodes(bound, node)
+	}
+
+	if !m.satisfy(name, node) {
+		return false
+	}
+
+	m.nodes[name] = node
+	return true
+}
+
+// satisfy reports whether the node satisfies the constraint of the metavariable.
+func (m *matcher) satisfy(name string, node ast.Node) bool {
+	if m.check == nil || m.err != nil {
+		return m.err == nil
+	}
+
+	ok, err := m.check(name, node)
+	if err != nil {
+		m.err = err
+		return false
+	}
+	return ok
+}
+
+func (m *matcher) bindList(name string, ns reflect.Value) bool {
+	list := make([]ast.Node, ns.Len())
+	for i := range list {
+		node, ok := ns.Index(i).Interface().(ast.Node)
+		if !ok {
+			return false
+		}
+		list[i] = node
+	}
+
+	if name == metaWildcard {
+		return true
+	}
+
+	if bound, ok := m.lists[name]; ok {
+		if len(bound) != len(list) {
+			return false
+		}
+		for i := range bound {
+			if !equalNodes(bound[i], list[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, node := range list {
+		if !m.satisfy(name, node) {
+			return false
+		}
+	}
+
+	m.lists[name] = list
+	return true
+}
+
+// equalNodes reports whether the nodes are structurally equal.
+func equalNodes(x, y ast.Node) bool {
+	m := &matcher{
+		nodes: make(map[string]ast.Node),
+		lists: make(map[string][]ast.Node),
+	}
+	return m.match(reflect.ValueOf(x), reflect.ValueOf(y))
+}
+
+type bindings struct {
+	nodes map[string]ast.Node
+	lists map[string][]ast.Node
+}
+
+func (m *matcher) save() bindings {
+	b := bindings{
+		nodes: make(map[string]ast.Node, len(m.nodes)),
+		lists: make(map[string][]ast.Node, len(m.lists)),
+	}
+	for k, v := range m.nodes {
+		b.nodes[k] = v
+	}
+	for k, v := range m.lists {
+		b.lists[k] = v
+	}
+	return b
+}
+
+func (m *matcher) restore(b bindings) {
+	m.nodes, m.lists = b.nodes, b.lists
+}
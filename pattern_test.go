@@ -0,0 +1,161 @@
+package astquery_test
+
+import (
+	"go/ast"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/packages"
+)
+
+func TestEvaluator_Match(t *testing.T) {
+	t.Parallel()
+
+	O := func(opts ...astquery.Option) []astquery.Option { return opts }
+	W := func(kv ...string) map[string]string {
+		m := make(map[string]string)
+		for i := 0; i < len(kv); i += 2 {
+			m[kv[i]] = kv[i+1]
+		}
+		return m
+	}
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Match", f) }
+	cases := map[string]struct {
+		path    string
+		opts    []astquery.Option
+		pattern string
+		where   map[string]string
+		want    []string
+		wantErr bool
+	}{
+		"call":           {TD("a.go"), nil, `fmt.Errorf($msg, $*args)`, nil, []string{`fmt.Errorf("f: %w", err) args=[err] msg="f: %w"`, `fmt.Errorf("n") args=[] msg="n"`, `fmt.Errorf("$x") args=[] msg="$x"`, `fmt.Errorf("%d %d", n, 1) args=[n 1] msg="%d %d"`}, false},
+		"literal":        {TD("a.go"), nil, `fmt.Errorf("n")`, nil, []string{`fmt.Errorf("n")`}, false},
+		"wildcard":       {TD("a.go"), nil, `fmt.Errorf($_, $_)`, nil, []string{`fmt.Errorf("f: %w", err)`}, false},
+		"same":           {TD("a.go"), nil, `fmt.Println($x, $x)`, nil, []string{`fmt.Println(n, n) x=n`}, false},
+		"stmt":           {TD("a.go"), nil, `if err != nil { return $_ }`, nil, []string{"if err != nil {\n\treturn fmt.Errorf(\"f: %w\", err)\n}", "if err != nil {\n\treturn err\n}"}, false},
+		"anystmt":        {TD("a.go"), nil, `if err != nil { $s }`, nil, []string{"if err != nil {\n\treturn fmt.Errorf(\"f: %w\", err)\n} s=return fmt.Errorf(\"f: %w\", err)", "if err != nil {\n\treturn err\n} s=return err"}, false},
+		"assign":         {TD("a.go"), nil, `$x = $x + 1`, nil, []string{`n = n + 1 x=n`}, false},
+		"decl":           {TD("a.go"), nil, `func $name($*params) error { $*_ }`, nil, []string{"func f name=f params=[err:error n:int]"}, false},
+		"nomatch":        {TD("a.go"), nil, `fmt.Sprintf($*_)`, nil, nil, false},
+		"multi":          {TD("a.go"), nil, `a(); b()`, nil, nil, true},
+		"syntax":         {TD("a.go"), nil, `fmt.Errorf(`, nil, nil, true},
+		"dollar":         {TD("a.go"), nil, `fmt.Errorf("$x")`, nil, []string{`fmt.Errorf("$x")`}, false},
+		"rune":           {TD("a.go"), nil, `'$'`, nil, nil, false},
+		"comment":        {TD("a.go"), nil, `fmt.Errorf($msg /* $*args */)`, nil, []string{`fmt.Errorf("n") msg="n"`, `fmt.Errorf("$x") msg="$x"`}, false},
+		"list":           {TD("a.go"), nil, `$*xs`, nil, nil, true},
+		"liststmt":       {TD("a.go"), nil, `$*_;`, nil, nil, true},
+		"typenames":      {TD("a.go"), O(astquery.WithTypeNames()), `fmt.Errorf($msg, $*args, 1)`, nil, []string{`fmt.Errorf("%d %d", n, 1) args=[n] msg="%d %d"`}, false},
+		"typenamesfirst": {TD("a.go"), O(astquery.WithTypeNames()), `fmt.Println(n, $x)`, nil, []string{`fmt.Println(n, n) x=n`, `fmt.Println(n, 1) x=1`}, false},
+		"where":          {TD("a.go"), nil, `fmt.Errorf($msg, $*args)`, W("args", "@typeof='int'"), []string{`fmt.Errorf("n") args=[] msg="n"`, `fmt.Errorf("$x") args=[] msg="$x"`, `fmt.Errorf("%d %d", n, 1) args=[n 1] msg="%d %d"`}, false},
+		"wherefunc":      {TD("a.go"), nil, `fmt.Errorf($msg, $*_)`, W("msg", "go:line() < 15"), []string{`fmt.Errorf("f: %w", err) msg="f: %w"`, `fmt.Errorf("n") msg="n"`}, false},
+		"wherenode":      {TD("a.go"), nil, `$x = $x + $y`, W("y", "self::*[@type='BasicLit']"), []string{`n = n + 1 x=n y=1`}, false},
+		"wherenone":      {TD("a.go"), nil, `$x = $x + $y`, W("y", "@Name='m'"), nil, false},
+		"wherenovar":     {TD("a.go"), nil, `fmt.Errorf($msg)`, W("args", "true()"), nil, true},
+		"wherewild":      {TD("a.go"), nil, `fmt.Errorf($_)`, W("_", "true()"), nil, true},
+		"ellipsis":       {TD("ellipsis.go"), nil, `f($x)`, nil, []string{`f(x) x=x`}, false},
+		"ellipsisdots":   {TD("ellipsis.go"), nil, `f($x...)`, nil, []string{`f(s...) x=s`}, false},
+		"ellipsissame":   {TD("ellipsis.go"), nil, `$s = append($s, $s)`, nil, nil, false},
+		"ellipsistypes":  {TD("ellipsis.go"), O(astquery.WithTypeNames()), `f($x)`, nil, []string{`f(x) x=x`}, false},
+		"alias":          {TD("ellipsis.go"), nil, `type $t = int`, nil, []string{`type A = int t=A`}, false},
+		"noalias":        {TD("ellipsis.go"), nil, `type $t int`, nil, []string{`type B int t=B`}, false},
+		"whereinvalid":   {TD("a.go"), nil, `fmt.Errorf($msg)`, W("msg", "@typeof="), nil, true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			p, err := astquery.ParsePattern(tt.pattern)
+			for name, expr := range tt.where {
+				if err == nil {
+					err = p.Where(name, expr)
+				}
+			}
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			e := newEvaluator(t, tt.path, tt.opts...)
+			ms, err := e.Match(p)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			var got []string
+			for _, m := range ms {
+				got = append(got, matchString(e, m))
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Match_Package(t *testing.T) {
+	t.Parallel()
+
+	fset := token.NewFileSet()
+	pkgs := []*packages.Package{
+		newPackage(t, fset, filepath.Join("testdata", "TestNewPackages", "a.go"), "example.com/a"),
+		newPackage(t, fset, filepath.Join("testdata", "TestNewPackages", "b.go"), "example.com/b"),
+	}
+	e := astquery.NewPackages(fset, pkgs)
+
+	ms, err := e.Match(astquery.MustParsePattern(`$x`))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	if len(ms) == 0 {
+		t.Fatal("$x must match nodes")
+	}
+
+	for _, m := range ms {
+		if p, ok := m.Node.(*astquery.Package); ok {
+			t.Errorf("$x must not match a package %v", p)
+		}
+	}
+}
+
+func matchString(e *astquery.Evaluator, m *astquery.Match) string {
+	attr := func(n ast.Node, name string) string {
+		v, _ := e.Attribute(n, name)
+		return v
+	}
+	src := func(n ast.Node) string {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			return "func " + n.Name.Name
+		case *ast.Field:
+			return attr(n, "Names") + ":" + attr(n.Type, "src")
+		}
+		return attr(n, "src")
+	}
+
+	var bs []string
+	for name, n := range m.Nodes {
+		bs = append(bs, name+"="+src(n))
+	}
+	for name, ns := range m.Lists {
+		ss := make([]string, len(ns))
+		for i := range ns {
+			ss[i] = src(ns[i])
+		}
+		bs = append(bs, name+"=["+strings.Join(ss, " ")+"]")
+	}
+	sort.Strings(bs)
+
+	return strings.Join(append([]string{src(m.Node)}, bs...), " ")
+}
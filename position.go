@@ -0,0 +1,32 @@
+package astquery
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+)
+
+// positionNames are names of attributes which represent the position of a node.
+// Numeric attributes can be compared as numbers in XPath.
+var positionNames = []string{"file", "line", "col", "offset", "endpos", "endline", "endcol"}
+
+// positionValue returns a value of the position attribute.
+func positionValue(fset *token.FileSet, n ast.Node, name string) (string, bool) {
+	switch name {
+	case "file":
+		return fset.Position(n.Pos()).Filename, true
+	case "line":
+		return strconv.Itoa(fset.Position(n.Pos()).Line), true
+	case "col":
+		return strconv.Itoa(fset.Position(n.Pos()).Column), true
+	case "offset":
+		return strconv.Itoa(fset.Position(n.Pos()).Offset), true
+	case "endpos":
+		return fset.Position(n.End()).String(), true
+	case "endline":
+		return strconv.Itoa(fset.Position(n.End()).Line), true
+	case "endcol":
+		return strconv.Itoa(fset.Position(n.End()).Column), true
+	}
+	return "", false
+}
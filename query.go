@@ -0,0 +1,167 @@
+package astquery
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/antchfx/xpath"
+)
+
+// Query is a compiled XPath expression.
+// A Query can be used by multiple Evaluators concurrently.
+type Query struct {
+	src   string
+	tmpl  string // the expression whose function calls are replaced with placeholders
+	calls []*funcCall
+
+	// compiled expressions for each kinds of the functions
+	// because kinds of registered functions depend on Evaluators
+	compiled sync.Map // string -> *compiledQuery
+}
+
+// compiledQuery is a Query which is compiled with kinds of its function calls.
+type compiledQuery struct {
+	expr *compiledExpr
+	args map[*funcCall][]*compiledExpr
+}
+
+// Compile compiles the XPath expression.
+// The expression can call functions of the Go-aware library such as go:line().
+// Functions which are registered to an Evaluator are resolved in evaluation.
+func Compile(expr string) (*Query, error) {
+	var calls []*funcCall
+	tmpl, err := compileCalls(expr, &calls)
+	if err != nil {
+		return nil, fmt.Errorf("expr cannot compile: %w", err)
+	}
+
+	q := &Query{src: expr, tmpl: tmpl, calls: calls}
+
+	// registered functions are assumed to return strings to check the syntax
+	kinds := make([]Kind, len(calls))
+	for i, c := range calls {
+		kinds[i] = KindString
+		if c.def != nil {
+			kinds[i] = c.def.kind
+		}
+	}
+
+	if _, err := q.compile(kinds); err != nil {
+		return nil, fmt.Errorf("expr cannot compile: %w", err)
+	}
+
+	return q, nil
+}
+
+// MustCompile is like Compile but panics if the expression cannot be compiled.
+// It is useful to initialize global variables.
+func MustCompile(expr string) *Query {
+	q, err := Compile(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source XPath expression.
+func (q *Query) String() string {
+	return q.src
+}
+
+// compileFor compiles the query with kinds of the functions which are registered to the navigator.
+func (q *Query) compileFor(n *NodeNavigator) (*compiledQuery, error) {
+	kinds := make([]Kind, len(q.calls))
+	for i, c := range q.calls {
+		def := c.lookup(n.funcs)
+		if def == nil {
+			return nil, fmt.Errorf("unknown function %s:%s()", c.prefix, c.name)
+		}
+		kinds[i] = def.kind
+	}
+
+	cq, err := q.compile(kinds)
+	if err != nil {
+		return nil, fmt.Errorf("expr cannot compile: %w", err)
+	}
+
+	return cq, nil
+}
+
+func (q *Query) compile(kinds []Kind) (*compiledQuery, error) {
+	key := fmt.Sprint(kinds)
+	if cq, ok := q.compiled.Load(key); ok {
+		return cq.(*compiledQuery), nil
+	}
+
+	expr, err := compileExpr(resolveCalls(q.tmpl, q.calls, kinds))
+	if err != nil {
+		return nil, err
+	}
+
+	cq := &compiledQuery{
+		expr: expr,
+		args: make(map[*funcCall][]*compiledExpr, len(q.calls)),
+	}
+	for _, c := range q.calls {
+		args := make([]*compiledExpr, len(c.args))
+		for i := range c.args {
+			args[i], err = compileExpr(resolveCalls(c.args[i], q.calls, kinds))
+			if err != nil {
+				return nil, fmt.Errorf("argument %d of %s:%s(): %w", i+1, c.prefix, c.name, err)
+			}
+		}
+		cq.args[c] = args
+	}
+
+	actual, _ := q.compiled.LoadOrStore(key, cq)
+	return actual.(*compiledQuery), nil
+}
+
+// compiledExpr is a compiled XPath expression which can be evaluated concurrently.
+// xpath.Expr keeps states of an evaluation in itself,
+// so each evaluation uses its own xpath.Expr from the pool.
+type compiledExpr struct {
+	src  string
+	pool sync.Pool // *xpath.Expr
+}
+
+func compileExpr(src string) (*compiledExpr, error) {
+	expr, err := xpath.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &compiledExpr{src: src}
+	c.pool.Put(expr)
+	return c, nil
+}
+
+func (c *compiledExpr) get() *xpath.Expr {
+	if expr, ok := c.pool.Get().(*xpath.Expr); ok {
+		return expr
+	}
+
+	// the source has been compiled successfully once
+	expr, err := xpath.Compile(c.src)
+	if err != nil {
+		panic(err)
+	}
+	return expr
+}
+
+// Evaluate is same as xpath.Expr.Evaluate.
+// If the evaluation panics, the xpath.Expr is not reused.
+func (c *compiledExpr) Evaluate(root xpath.NodeNavigator) interface{} {
+	expr := c.get()
+	v := expr.Evaluate(root)
+	c.pool.Put(expr)
+	return v
+}
+
+// Select is same as xpath.Expr.Select.
+func (c *compiledExpr) Select(root xpath.NodeNavigator) *xpath.NodeIterator {
+	expr := c.get()
+	iter := expr.Select(root)
+	c.pool.Put(expr)
+	return iter
+}
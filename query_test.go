@@ -0,0 +1,113 @@
+package astquery_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestCompile(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		xpath   string
+		wantErr bool
+	}{
+		"valid":   {"//*[@type='CallExpr']", false},
+		"invalid": {"//*[@type='CallExpr'", true},
+		"unknown": {"unknown()", true},
+		"go":      {"//*[go:exported(@Name) and go:line() > 10]", false},
+		"go-args": {"//*[go:line(1)]", true},
+		"go-func": {"//*[go:unknown()]", true},
+		"prefix":  {"//*[my:func()]", false},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			q, err := astquery.Compile(tt.xpath)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Error("expected error does not occur")
+			case !tt.wantErr && err != nil:
+				t.Error("unexpected error:", err)
+			case !tt.wantErr && q.String() != tt.xpath:
+				t.Errorf("String() = %q, want %q", q.String(), tt.xpath)
+			}
+		})
+	}
+}
+
+func TestEvaluator_SelectQuery(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Select", f) }
+	q := astquery.MustCompile("/*/Decls[1]/Body/*")
+	cases := map[string]struct {
+		path string
+		want []string
+	}{
+		"single": {TD("single.go"), S("ReturnStmt")},
+		"multi":  {TD("multi.go"), S("AssignStmt", "ReturnStmt")},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			ns, err := e.SelectQuery(q)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+			got := nodesType(t, ns)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// TestQuery_Concurrent evaluates a query from goroutines.
+// It should be run with -race.
+func TestQuery_Concurrent(t *testing.T) {
+	t.Parallel()
+
+	// a boolean operator selects nodes by the shared query without cloning it
+	q := astquery.MustCompile("//*[@type='ReturnStmt'] and //*[@type='AssignStmt']")
+	sel := astquery.MustCompile("//*[@type='AssignStmt' or @type='ReturnStmt']")
+	e := newEvaluator(t, filepath.Join("testdata", "TestEvaluator_Select", "multi.go"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				v, err := e.EvalQuery(q)
+				if err != nil {
+					t.Error("unexpected error:", err)
+					return
+				}
+				if v != true {
+					t.Errorf("want true but got %v", v)
+				}
+
+				ns, err := e.SelectQuery(sel)
+				if err != nil {
+					t.Error("unexpected error:", err)
+					return
+				}
+				if got := nodesType(t, ns); len(got) != 2 {
+					t.Errorf("want 2 nodes but got %v", got)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
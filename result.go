@@ -0,0 +1,141 @@
+package astquery
+
+import (
+	"go/ast"
+	"go/token"
+	"strconv"
+
+	"github.com/antchfx/xpath"
+)
+
+// Kind represents a kind of a Result.
+type Kind int
+
+// Kinds of a Result.
+const (
+	KindBool Kind = iota
+	KindNumber
+	KindString
+	KindNodeSet
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindBool:
+		return "bool"
+	case KindNumber:
+		return "number"
+	case KindString:
+		return "string"
+	case KindNodeSet:
+		return "node-set"
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Attribute is an attribute of a node.
+type Attribute struct {
+	Node     ast.Node // owner of the attribute
+	Name     string
+	Value    string
+	Position token.Position // position of the owner
+}
+
+// Result is a result of an evaluation of an expression.
+type Result struct {
+	fset  *token.FileSet
+	kind  Kind
+	b     bool
+	num   float64
+	str   string
+	nodes []ast.Node // elements and attributes in document order
+}
+
+func newResult(fset *token.FileSet, v interface{}) *Result {
+	switch v := v.(type) {
+	case bool:
+		return &Result{fset: fset, kind: KindBool, b: v}
+	case float64:
+		return &Result{fset: fset, kind: KindNumber, num: v}
+	case string:
+		return &Result{fset: fset, kind: KindString, str: v}
+	case *xpath.NodeIterator:
+		return &Result{fset: fset, kind: KindNodeSet, nodes: nodes(v)}
+	}
+	panic("unexpected result type")
+}
+
+// Kind returns the kind of the result.
+func (r *Result) Kind() Kind {
+	return r.kind
+}
+
+// Bool returns the value of the result if the kind is KindBool.
+// Otherwise it returns false.
+func (r *Result) Bool() bool {
+	return r.b
+}
+
+// Number returns the value of the result if the kind is KindNumber.
+// Otherwise it returns 0.
+func (r *Result) Number() float64 {
+	return r.num
+}
+
+// String returns the value of the result if the kind is KindString.
+// Otherwise it returns an empty string.
+func (r *Result) String() string {
+	return r.str
+}
+
+// Nodes returns element nodes in the result if the kind is KindNodeSet.
+// Attributes are not contained.
+func (r *Result) Nodes() []ast.Node {
+	var ns []ast.Node
+	for _, n := range r.nodes {
+		if _, ok := n.(attr); !ok {
+			ns = append(ns, n)
+		}
+	}
+	return ns
+}
+
+// Attributes returns attributes in the result if the kind is KindNodeSet.
+func (r *Result) Attributes() []*Attribute {
+	var attrs []*Attribute
+	for _, n := range r.nodes {
+		if a, ok := n.(attr); ok {
+			attrs = append(attrs, &Attribute{
+				Node:     a.parent,
+				Name:     a.name,
+				Value:    a.value(),
+				Position: r.fset.Position(a.parent.Pos()),
+			})
+		}
+	}
+	return attrs
+}
+
+// value returns the result as a value which is returned by Eval.
+func (r *Result) value() interface{} {
+	switch r.kind {
+	case KindBool:
+		return r.b
+	case KindNumber:
+		return r.num
+	case KindString:
+		return r.str
+	}
+
+	vs := make([]interface{}, 0, len(r.nodes))
+	for i := range r.nodes {
+		switch n := r.nodes[i].(type) {
+		case attr:
+			vs = append(vs, n.value())
+		}
+	}
+	if len(vs) == len(r.nodes) {
+		return vs
+	}
+	return r.nodes
+}
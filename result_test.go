@@ -0,0 +1,67 @@
+package astquery_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Eval", f) }
+	type result struct {
+		Kind   astquery.Kind
+		Bool   bool
+		Number float64
+		String string
+		Nodes  []string
+		Attrs  []string // owner's type and value
+	}
+	cases := map[string]struct {
+		path  string
+		xpath string
+		want  result
+	}{
+		"bool":   {TD("attr.go"), "count(//*[@type='CallExpr']) > 1", result{Kind: astquery.KindBool, Bool: true}},
+		"number": {TD("attr.go"), "count(//*[@type='CallExpr'])", result{Kind: astquery.KindNumber, Number: 4}},
+		"string": {TD("attr.go"), "string(//*[@type='CallExpr']/Fun/@Name)", result{Kind: astquery.KindString, String: "print"}},
+		"nodes":  {TD("attr.go"), "//*[@type='CallExpr']/Fun[@Name='println']", result{Kind: astquery.KindNodeSet, Nodes: S("Ident")}},
+		"attrs":  {TD("attr.go"), "//*[@type='CallExpr']/Fun[@Name='println']/@Name", result{Kind: astquery.KindNodeSet, Attrs: S("Ident:println")}},
+		"mixed": {TD("attr.go"), "//*[@type='CallExpr'][Fun/@Name='println'] | //*[@type='CallExpr']/Fun[@Name='println']/@Name", result{
+			Kind:  astquery.KindNodeSet,
+			Nodes: S("CallExpr"),
+			Attrs: S("Ident:println"),
+		}},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+			r, err := e.Evaluate(tt.xpath)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			got := result{
+				Kind:   r.Kind(),
+				Bool:   r.Bool(),
+				Number: r.Number(),
+				String: r.String(),
+				Nodes:  nodesType(t, r.Nodes()),
+			}
+			for _, a := range r.Attributes() {
+				got.Attrs = append(got.Attrs, nodeType(t, a.Node)+":"+a.Value)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
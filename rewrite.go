@@ -0,0 +1,176 @@
+package astquery
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"sort"
+)
+
+// Edit is a replacement of a range of source code.
+type Edit struct {
+	Node    ast.Node // replaced node
+	Pos     token.Pos
+	End     token.Pos
+	NewText []byte
+	Nested  []ast.Node // selected nodes which are contained in Node and are not replaced
+}
+
+// Rewrite is a result of Replace for a file.
+type Rewrite struct {
+	Filename string
+	Src      []byte // original source code
+	Out      []byte // rewritten source code formatted by go/format
+	Edits    []*Edit
+}
+
+// Replace replaces nodes which match the XPath expr with the template.
+// See Template for the syntax of the template.
+// Source code is read from files which are recorded in the token.FileSet.
+// Replace does not write files, it returns rewritten source code of each changed file.
+// Only the outermost nodes are replaced.
+// If a selected node is contained in another selected node, it is not replaced
+// and it is recorded in Edit.Nested of the outer node.
+func (e *Evaluator) Replace(expr, template string) ([]*Rewrite, error) {
+	q, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := ParseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.ReplaceQuery(q, t)
+}
+
+// ReplaceQuery replaces nodes which match the compiled query with the template.
+func (e *Evaluator) ReplaceQuery(q *Query, t *Template) ([]*Rewrite, error) {
+	var rs []*Rewrite
+	err := e.ReplaceQueryFunc(q, t, func(r *Rewrite) error {
+		rs = append(rs, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// ReplaceQueryFunc is like ReplaceQuery but calls fn with the rewrite of each changed file
+// instead of returning all of them.
+// A file is read just before its rewrite and ReplaceQueryFunc does not keep it after fn returns,
+// so source code of only one file is held in memory at a time.
+// If fn returns an error, ReplaceQueryFunc stops and returns the error.
+func (e *Evaluator) ReplaceQueryFunc(q *Query, t *Template, fn func(*Rewrite) error) error {
+	ns, err := e.SelectQuery(q)
+	if err != nil {
+		return err
+	}
+
+	edits, err := e.Edits(ns, t)
+	if err != nil {
+		return err
+	}
+
+	return e.rewrite(edits, fn)
+}
+
+// Edits returns edits which replace the nodes with the template.
+// The edits are sorted by their positions.
+// Nodes which are contained in another node are not replaced and are recorded in Edit.Nested.
+func (e *Evaluator) Edits(ns []ast.Node, t *Template) ([]*Edit, error) {
+	sorted := make([]ast.Node, 0, len(ns))
+	for _, n := range ns {
+		switch n.(type) {
+		case *pkg, *Package, *ast.File:
+			return nil, fmt.Errorf("%T cannot be replaced", n)
+		}
+		if n.Pos().IsValid() {
+			sorted = append(sorted, n)
+		}
+	}
+
+	// outer nodes come first
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Pos() != sorted[j].Pos() {
+			return sorted[i].Pos() < sorted[j].Pos()
+		}
+		return sorted[i].End() > sorted[j].End()
+	})
+
+	var edits []*Edit
+	for _, n := range sorted {
+		if len(edits) > 0 && n.Pos() < edits[len(edits)-1].End {
+			last := edits[len(edits)-1]
+			last.Nested = append(last.Nested, n)
+			continue
+		}
+
+		text, err := t.execute(e.n, n)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", e.n.fset.Position(n.Pos()), err)
+		}
+
+		edits = append(edits, &Edit{
+			Node:    n,
+			Pos:     n.Pos(),
+			End:     n.End(),
+			NewText: []byte(text),
+		})
+	}
+
+	return edits, nil
+}
+
+// rewrite applies the edits to the source files and calls fn for each file.
+// The edits are sorted by their positions, so the edits of a file are consecutive.
+func (e *Evaluator) rewrite(edits []*Edit, fn func(*Rewrite) error) error {
+	for len(edits) > 0 {
+		tf := e.n.fset.File(edits[0].Pos)
+		n := 1
+		for n < len(edits) && e.n.fset.File(edits[n].Pos) == tf {
+			n++
+		}
+
+		r, err := rewriteFile(tf, edits[:n:n])
+		if err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+		edits = edits[n:]
+	}
+
+	return nil
+}
+
+// rewriteFile reads the file and applies the edits to it.
+func rewriteFile(tf *token.File, edits []*Edit) (*Rewrite, error) {
+	src, err := ioutil.ReadFile(tf.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	var last int
+	for _, edit := range edits {
+		start, end := tf.Offset(edit.Pos), tf.Offset(edit.End)
+		buf.Write(src[last:start])
+		buf.Write(edit.NewText)
+		last = end
+	}
+	buf.Write(src[last:])
+
+	out, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("%s: rewritten source cannot be formatted: %w", tf.Name(), err)
+	}
+
+	return &Rewrite{Filename: tf.Name(), Src: src, Out: out, Edits: edits}, nil
+}
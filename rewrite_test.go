@@ -0,0 +1,167 @@
+package astquery_test
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/txtar"
+)
+
+func TestEvaluator_Replace(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_Replace", f) }
+	cases := map[string]struct {
+		path     string
+		xpath    string
+		template string
+		wantErr  bool
+	}{
+		"errorf":   {TD("errorf.go"), "//*[@type='CallExpr'][Fun/X/@Name='fmt' and Fun/Sel/@Name='Errorf' and count(Args)=1]", "errors.New({{.Args[0].src}})", false},
+		"nested":   {TD("nested.go"), "//*[@type='CallExpr'][Fun/@Name='g']", "h({{.Args[0]}})", false},
+		"files":    {TD("files.go"), "//*[@type='CallExpr'][Fun/@Name='g']", "h({{.Args[0]}})", false},
+		"comment":  {TD("comment.go"), "//*[@type='CallExpr'][Fun/@Name='g']", "h({{.Args[0]}})", false},
+		"attr":     {TD("attr.go"), "//*[@type='CallExpr'][Args/@type='Ident']", "{{.Fun.Name}}2({{ .Args[0].Name }})", false},
+		"noattr":   {TD("nested.go"), "//*[@type='CallExpr']", "{{.Fun.nothing}}", true},
+		"nonode":   {TD("nested.go"), "//*[@type='CallExpr']", "{{.Args[1]}}", true},
+		"badpath":  {TD("nested.go"), "//*[@type='CallExpr']", "{{Args}}", true},
+		"unclosed": {TD("nested.go"), "//*[@type='CallExpr']", "{{.Args", true},
+		"file":     {TD("nested.go"), "//*[@type='File']", "package b", true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e, golden := newFileEvaluator(t, tt.path)
+			rs, err := e.Replace(tt.xpath, tt.template)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			got := make(map[string]string)
+			for _, r := range rs {
+				got[filepath.Base(r.Filename)] = string(r.Out)
+			}
+			if diff := cmp.Diff(golden, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEvaluator_ReplaceQueryFunc(t *testing.T) {
+	t.Parallel()
+
+	q := astquery.MustCompile("//*[@type='CallExpr'][Fun/@Name='g']")
+	tmpl := astquery.MustParseTemplate("h({{.Args[0]}})")
+	stop := errors.New("stop")
+	cases := map[string]struct {
+		err     error
+		want    []string
+		wantErr error
+	}{
+		"all":  {nil, []string{"a.go", "b.go"}, nil},
+		"stop": {stop, []string{"a.go"}, stop},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e, golden := newFileEvaluator(t, filepath.Join("testdata", "TestEvaluator_Replace", "files.go"))
+			var got []string
+			err := e.ReplaceQueryFunc(q, tmpl, func(r *astquery.Rewrite) error {
+				name := filepath.Base(r.Filename)
+				got = append(got, name)
+				if diff := cmp.Diff(golden[name], string(r.Out)); diff != "" {
+					t.Error(diff)
+				}
+				return tt.err
+			})
+			if err != tt.wantErr {
+				t.Errorf("want error %v but got %v", tt.wantErr, err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Edits(t *testing.T) {
+	t.Parallel()
+
+	e, _ := newFileEvaluator(t, filepath.Join("testdata", "TestEvaluator_Replace", "nested.go"))
+	ns, err := e.Select("//*[@type='CallExpr'][Fun/@Name='g']")
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	edits, err := e.Edits(ns, astquery.MustParseTemplate("h({{.Args[0]}})"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var got []string
+	for _, edit := range edits {
+		s := string(edit.NewText)
+		for _, n := range edit.Nested {
+			s += " nested:" + nodeType(t, n)
+		}
+		got = append(got, s)
+	}
+
+	want := []string{"h(g(x)) nested:CallExpr"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+// newFileEvaluator writes the files in the txtar file to a temporary directory and creates an Evaluator.
+// Files which have .golden suffix are not written and returned as a map.
+func newFileEvaluator(t *testing.T, path string) (*astquery.Evaluator, map[string]string) {
+	t.Helper()
+	ar, err := txtar.ParseFile(path)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	dir := t.TempDir()
+	fset := token.NewFileSet()
+	var files []*ast.File
+	golden := make(map[string]string)
+	for _, f := range ar.Files {
+		if strings.HasSuffix(f.Name, ".golden") {
+			golden[strings.TrimSuffix(f.Name, ".golden")] = string(f.Data)
+			continue
+		}
+
+		fpath := filepath.Join(dir, f.Name)
+		if err := ioutil.WriteFile(fpath, f.Data, 0o666); err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+
+		file, err := parser.ParseFile(fset, fpath, nil, parser.ParseComments)
+		if err != nil {
+			t.Fatal("unexpected error:", err)
+		}
+		files = append(files, file)
+	}
+
+	pkg, info := typecheck(t, fset, "a", files)
+	return astquery.New(fset, files, nil, astquery.WithTypesInfo(pkg, info)), golden
+}
@@ -0,0 +1,251 @@
+package astquery
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+// Severity is a severity of a rule.
+type Severity string
+
+// Severities of rules.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Rule is a lint rule which reports nodes selected by an XPath expression.
+type Rule struct {
+	ID       string   `json:"id"`
+	XPath    string   `json:"xpath"`
+	Message  string   `json:"message"`            // template which refers attributes of the node, see Message
+	Severity Severity `json:"severity,omitempty"` // default is SeverityWarning
+	// Fix is a template which replaces the reported node as a suggested fix (optional).
+	// See Template.
+	Fix string `json:"fix,omitempty"`
+}
+
+// EffectiveSeverity returns the severity of the rule.
+// If the severity is not specified, it returns SeverityWarning.
+func (r *Rule) EffectiveSeverity() Severity {
+	if r.Severity == "" {
+		return SeverityWarning
+	}
+	return r.Severity
+}
+
+// LoadRules loads rules from JSON such as the follows.
+//	{
+//		"rules": [
+//			{
+//				"id": "dontpanic",
+//				"xpath": "//*[@type='CallExpr']/Fun[@type='Ident' and @Name='panic']",
+//				"message": "don't panic",
+//				"severity": "error"
+//			}
+//		]
+//	}
+//
+// IDs of the rules must be unique.
+func LoadRules(r io.Reader) ([]*Rule, error) {
+	var file struct {
+		Rules []*Rule `json:"rules"`
+	}
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("rules cannot load: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, rule := range file.Rules {
+		if err := rule.validate(); err != nil {
+			return nil, fmt.Errorf("rules cannot load: %w", err)
+		}
+		if seen[rule.ID] {
+			return nil, fmt.Errorf("rules cannot load: rule %s is duplicated", rule.ID)
+		}
+		seen[rule.ID] = true
+	}
+
+	return file.Rules, nil
+}
+
+// LoadRulesFile loads rules from the JSON file.
+// See LoadRules.
+func LoadRulesFile(path string) ([]*Rule, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadRules(f)
+}
+
+func (r *Rule) validate() error {
+	if r.ID == "" {
+		return errors.New("rule must have an id")
+	}
+
+	if r.XPath == "" {
+		return fmt.Errorf("rule %s must have an xpath", r.ID)
+	}
+
+	switch r.Severity {
+	case "", SeverityError, SeverityWarning, SeverityInfo:
+	default:
+		return fmt.Errorf("rule %s has an invalid severity %q", r.ID, r.Severity)
+	}
+
+	return nil
+}
+
+// compiledRule is a rule whose XPath expression and fix template are compiled.
+type compiledRule struct {
+	*Rule
+	query *Query
+	msg   *Message
+	fix   *Template
+}
+
+func compileRule(r *Rule) (*compiledRule, error) {
+	if err := r.validate(); err != nil {
+		return nil, err
+	}
+
+	q, err := Compile(r.XPath)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+
+	msg, err := ParseMessage(r.Message)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+	}
+
+	var fix *Template
+	if r.Fix != "" {
+		fix, err = ParseTemplate(r.Fix)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+	}
+
+	return &compiledRule{Rule: r, query: q, msg: msg, fix: fix}, nil
+}
+
+// NewRuleAnalyzer creates an analyzer which reports nodes selected by the rules.
+// The category of each diagnostic is the ID of the rule.
+func NewRuleAnalyzer(name string, rules []*Rule) (*analysis.Analyzer, error) {
+	crs := make([]*compiledRule, len(rules))
+	ids := make([]string, len(rules))
+	seen := make(map[string]bool)
+	for i, r := range rules {
+		cr, err := compileRule(r)
+		if err != nil {
+			return nil, err
+		}
+		if seen[r.ID] {
+			return nil, fmt.Errorf("rule %s is duplicated", r.ID)
+		}
+		seen[r.ID] = true
+		crs[i], ids[i] = cr, r.ID
+	}
+
+	return &analysis.Analyzer{
+		Name: name,
+		Doc:  name + " checks rules: " + strings.Join(ids, ", "),
+		Run:  (&ruleAnalyzer{rules: crs}).run,
+		Requires: []*analysis.Analyzer{
+			Analyzer,
+		},
+		ResultType: reflect.TypeOf([]*Finding(nil)),
+	}, nil
+}
+
+// Finding is a diagnostic which is reported by a rule.
+// An analyzer created by NewRuleAnalyzer returns findings as its result.
+type Finding struct {
+	Rule       *Rule
+	Node       ast.Node
+	Diagnostic analysis.Diagnostic
+}
+
+type ruleAnalyzer struct {
+	rules []*compiledRule
+}
+
+func (a *ruleAnalyzer) run(pass *analysis.Pass) (interface{}, error) {
+	e := pass.ResultOf[Analyzer].(*Evaluator)
+	findings, err := e.checkRules(a.rules)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range findings {
+		pass.Report(f.Diagnostic)
+	}
+
+	return findings, nil
+}
+
+// CheckRules returns findings of the rules.
+// The category of each diagnostic is the ID of the rule.
+func (e *Evaluator) CheckRules(rules []*Rule) ([]*Finding, error) {
+	crs := make([]*compiledRule, len(rules))
+	for i := range rules {
+		cr, err := compileRule(rules[i])
+		if err != nil {
+			return nil, err
+		}
+		crs[i] = cr
+	}
+	return e.checkRules(crs)
+}
+
+func (e *Evaluator) checkRules(rules []*compiledRule) ([]*Finding, error) {
+	var findings []*Finding
+	for _, r := range rules {
+		result, err := e.EvaluateQuery(r.query)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+		}
+
+		if result.Kind() != KindNodeSet {
+			return nil, fmt.Errorf("rule %s: the result must be a node set but got %v", r.ID, result.Kind())
+		}
+
+		// owners of attributes are reported
+		ns := result.Nodes()
+		for _, a := range result.Attributes() {
+			ns = append(ns, a.Node)
+		}
+
+		for _, n := range ns {
+			msg, err := e.Message(r.msg, n)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+			}
+
+			d, err := e.Diagnostic(n, msg, r.fix)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %w", r.ID, err)
+			}
+
+			d.Category = r.ID
+			findings = append(findings, &Finding{Rule: r.Rule, Node: n, Diagnostic: d})
+		}
+	}
+
+	return findings, nil
+}
@@ -0,0 +1,136 @@
+package astquery_test
+
+import (
+	"fmt"
+	"go/token"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+	"golang.org/x/tools/go/analysis"
+)
+
+func TestLoadRules(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestLoadRules", f) }
+	cases := map[string]struct {
+		path    string
+		want    []string // id:severity:effective severity:fix
+		wantErr bool
+	}{
+		"valid":    {TD("valid.json"), S("dontpanic:error:error:", "errorsnew::warning:errors.New({{.Args[0]}})"), false},
+		"noid":     {TD("noid.json"), nil, true},
+		"noxpath":  {TD("noxpath.json"), nil, true},
+		"severity": {TD("severity.json"), nil, true},
+		"unknown":  {TD("unknown.json"), nil, true},
+		"dup":      {TD("dup.json"), nil, true},
+		"notexist": {TD("notexist.json"), nil, true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			rules, err := astquery.LoadRulesFile(tt.path)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			var got []string
+			for _, r := range rules {
+				got = append(got, fmt.Sprintf("%s:%s:%s:%s", r.ID, r.Severity, r.EffectiveSeverity(), r.Fix))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestNewRuleAnalyzer(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestNewRuleAnalyzer", f) }
+	rules, err := astquery.LoadRulesFile(filepath.Join("testdata", "TestLoadRules", "valid.json"))
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	cases := map[string]struct {
+		path    string
+		rules   []*astquery.Rule
+		want    []string // line:category:message:fixes
+		wantErr bool
+	}{
+		"rules":     {TD("a.go"), rules, S("10:dontpanic:don't panic:0", "13:errorsnew:use errors.New:1"), false},
+		"message":   {TD("a.go"), []*astquery.Rule{{ID: "message", XPath: "//*[@type='CallExpr']/Fun[@type='Ident']", Message: "call to {{.Name}} at line {{.line}}{{.nothing}}"}}, S("10:message:call to panic at line 10:0"), false},
+		"attribute": {TD("a.go"), []*astquery.Rule{{ID: "attribute", XPath: "//*[@type='CallExpr']/Fun[@type='Ident']/@Name", Message: "{{.Name}}"}}, S("10:attribute:panic:0"), false},
+		"badmsg":    {TD("a.go"), []*astquery.Rule{{ID: "badmsg", XPath: "//*", Message: "{{.Name"}}, nil, true},
+		"norule":    {TD("a.go"), nil, nil, false},
+		"invalid":   {TD("a.go"), []*astquery.Rule{{ID: "invalid", XPath: "//*["}}, nil, true},
+		"duplicate": {TD("a.go"), []*astquery.Rule{rules[0], rules[0]}, nil, true},
+		"template":  {TD("a.go"), []*astquery.Rule{{ID: "template", XPath: "//*", Fix: "{{.Args"}}, nil, true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			a, err := astquery.NewRuleAnalyzer("rules", tt.rules)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			fset := token.NewFileSet()
+			files := parse(t, fset, tt.path)
+			pkg, info := typecheck(t, fset, "a", files)
+			var got []string
+			pass := &analysis.Pass{
+				Analyzer:  a,
+				Fset:      fset,
+				Files:     files,
+				Pkg:       pkg,
+				TypesInfo: info,
+				ResultOf: map[*analysis.Analyzer]interface{}{
+					astquery.Analyzer: astquery.New(fset, files, nil, astquery.WithTypesInfo(pkg, info)),
+				},
+				Report: func(d analysis.Diagnostic) {
+					got = append(got, fmt.Sprintf("%d:%s:%s:%d", fset.Position(d.Pos).Line, d.Category, d.Message, len(d.SuggestedFixes)))
+				},
+			}
+
+			result, err := a.Run(pass)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+
+			findings := result.([]*astquery.Finding)
+			if len(findings) != len(got) {
+				t.Errorf("the number of findings want %d got %d", len(got), len(findings))
+			}
+			for _, f := range findings {
+				if f.Diagnostic.Category != f.Rule.ID {
+					t.Errorf("unexpected finding: %#v", f)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package astquery
+
+import (
+	"encoding/json"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// SARIFReporter collects findings and writes them as a SARIF 2.1.0 log.
+// Report can be called concurrently such as from Run of analyzers.
+// Columns are counted in Unicode code points, so Report reads source files to convert them.
+// If a file cannot be read, columns in the file are byte offsets as same as token.Position.
+//
+// Example:
+//	func run(pass *analysis.Pass) (interface{}, error) {
+//		findings := pass.ResultOf[ruleAnalyzer].([]*astquery.Finding)
+//		for _, f := range findings {
+//			reporter.Report(pass.Fset, f)
+//		}
+//		return nil, nil
+//	}
+type SARIFReporter struct {
+	// BaseDir is a directory which URIs of files are relative to.
+	// If it is empty or a file is not in the directory, the URI of the file is absolute.
+	BaseDir string
+
+	mu      sync.Mutex
+	rules   []*Rule
+	index   map[string]int // rule ID -> index of rules
+	results []*sarifResult
+	files   map[string][]byte // file name -> source code, nil if the file cannot be read
+}
+
+// NewSARIFReporter creates a SARIFReporter.
+// The rules are written as rules of the tool even if they have no finding.
+func NewSARIFReporter(rules []*Rule) *SARIFReporter {
+	r := &SARIFReporter{index: make(map[string]int), files: make(map[string][]byte)}
+	for _, rule := range rules {
+		r.addRule(rule)
+	}
+	return r
+}
+
+func (r *SARIFReporter) addRule(rule *Rule) int {
+	if i, ok := r.index[rule.ID]; ok {
+		return i
+	}
+	r.index[rule.ID] = len(r.rules)
+	r.rules = append(r.rules, rule)
+	return len(r.rules) - 1
+}
+
+// Report adds the finding to the log.
+// The fset is used to convert positions of the finding.
+func (r *SARIFReporter) Report(fset *token.FileSet, f *Finding) {
+	endPos := f.Diagnostic.End
+	if !endPos.IsValid() {
+		endPos = f.Diagnostic.Pos
+	}
+	pos, end := fset.Position(f.Diagnostic.Pos), fset.Position(endPos)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := &sarifResult{
+		RuleID:    f.Rule.ID,
+		RuleIndex: r.addRule(f.Rule),
+		Level:     sarifLevel(f.Rule.EffectiveSeverity()),
+		Message:   sarifMessage{Text: f.Diagnostic.Message},
+	}
+
+	if pos.IsValid() {
+		result.Locations = []*sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: r.artifactLocation(pos.Filename),
+				Region: sarifRegion{
+					StartLine:   pos.Line,
+					StartColumn: r.column(fset, f.Diagnostic.Pos, pos),
+					EndLine:     end.Line,
+					EndColumn:   r.column(fset, endPos, end),
+				},
+			},
+		}}
+	}
+
+	r.results = append(r.results, result)
+}
+
+// column converts the byte-based column of the position to a column in Unicode code points.
+// pos is the position of p which may be adjusted by //line directives.
+func (r *SARIFReporter) column(fset *token.FileSet, p token.Pos, pos token.Position) int {
+	if pos.Column == 0 {
+		return 0 // unknown such as a position adjusted by //line directives without columns
+	}
+
+	raw := fset.PositionFor(p, false)
+	src, ok := r.files[raw.Filename]
+	if !ok {
+		var err error
+		src, err = ioutil.ReadFile(raw.Filename)
+		if err != nil || len(src) != fset.File(p).Size() {
+			src = nil
+		}
+		r.files[raw.Filename] = src
+	}
+
+	start := raw.Offset - (raw.Column - 1)
+	if src == nil || start < 0 || raw.Offset > len(src) {
+		return pos.Column
+	}
+
+	// the difference between bytes and code points before the position in the line
+	return pos.Column - (raw.Column - 1 - utf8.RuneCount(src[start:raw.Offset]))
+}
+
+func (r *SARIFReporter) artifactLocation(filename string) sarifArtifactLocation {
+	if r.BaseDir != "" {
+		if rel, err := filepath.Rel(r.BaseDir, filename); err == nil && !strings.HasPrefix(rel, "..") {
+			return sarifArtifactLocation{
+				URI:       (&url.URL{Path: filepath.ToSlash(rel)}).String(),
+				URIBaseID: sarifSrcRoot,
+			}
+		}
+	}
+	return sarifArtifactLocation{URI: fileURI(filename)}
+}
+
+// fileURI returns a file URI of the path.
+// If the path is relative, it returns a relative reference.
+func fileURI(path string) string {
+	if !filepath.IsAbs(path) {
+		return (&url.URL{Path: filepath.ToSlash(path)}).String()
+	}
+
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path // such as C:/foo on Windows
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// Write writes the log as JSON.
+func (r *SARIFReporter) Write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rules := make([]*sarifRule, len(r.rules))
+	for i, rule := range r.rules {
+		rules[i] = &sarifRule{
+			ID:                   rule.ID,
+			ShortDescription:     sarifMessage{Text: rule.Message},
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(rule.EffectiveSeverity())},
+			Properties:           sarifProperties{XPath: rule.XPath},
+		}
+	}
+
+	results := r.results
+	if results == nil {
+		results = []*sarifResult{} // results must be an array
+	}
+
+	run := &sarifRun{
+		ColumnKind: "unicodeCodePoints",
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "astquery",
+			InformationURI: "https://github.com/gostaticanalysis/astquery",
+			Rules:          rules,
+		}},
+		Results: results,
+	}
+
+	if r.BaseDir != "" {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			sarifSrcRoot: {URI: fileURI(r.BaseDir) + "/"},
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []*sarifRun{run},
+	})
+}
+
+// sarifLevel converts the severity to a level of SARIF.
+func sarifLevel(s Severity) string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityInfo:
+		return "note"
+	}
+	return "warning"
+}
+
+const sarifSrcRoot = "SRCROOT"
+
+type sarifLog struct {
+	Version string      `json:"version"`
+	Schema  string      `json:"$schema"`
+	Runs    []*sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	ColumnKind         string                           `json:"columnKind"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []*sarifResult                   `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string       `json:"name"`
+	InformationURI string       `json:"informationUri"`
+	Rules          []*sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+	Properties           sarifProperties    `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifProperties struct {
+	XPath string `json:"xpath"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string           `json:"ruleId"`
+	RuleIndex int              `json:"ruleIndex"`
+	Level     string           `json:"level"`
+	Message   sarifMessage     `json:"message"`
+	Locations []*sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndLine     int `json:"endLine"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
@@ -0,0 +1,199 @@
+package astquery_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestSARIFReporter(t *testing.T) {
+	t.Parallel()
+
+	S := func(s ...string) []string { return s }
+	TD := func(f string) string { return filepath.Join("testdata", "TestSARIFReporter", f) }
+	dontpanic := &astquery.Rule{ID: "dontpanic", XPath: "//*[@type='CallExpr']/Fun[@type='Ident' and @Name='panic']", Message: "don't {{.Name}}", Severity: astquery.SeverityError}
+	errorf := &astquery.Rule{ID: "errorf", XPath: "//*[@type='CallExpr'][Fun/Sel/@Name='Errorf']", Message: "Errorf", Severity: astquery.SeverityInfo}
+	unused := &astquery.Rule{ID: "unused", XPath: "//nothing", Message: "unused"}
+
+	type sarif struct {
+		Version string
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID                   string
+						DefaultConfiguration struct{ Level string }
+					}
+				}
+			}
+			Results []struct {
+				RuleID    string
+				RuleIndex int
+				Level     string
+				Message   struct{ Text string }
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI       string
+							URIBaseID string `json:"uriBaseId"`
+						}
+						Region struct{ StartLine, StartColumn, EndLine, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+
+	cases := map[string]struct {
+		path       string
+		registered []*astquery.Rule
+		checked    []*astquery.Rule
+		baseDir    string
+		wantRules  []string // id:level
+		want       []string // ruleId:ruleIndex:level:message:uri:uriBaseId:region
+	}{
+		"rules": {TD("a.go"), []*astquery.Rule{dontpanic, errorf, unused}, []*astquery.Rule{dontpanic, errorf}, ".",
+			S("dontpanic:error", "errorf:note", "unused:warning"),
+			S("dontpanic:0:error:don't panic:a.go:SRCROOT:10:3-10:8", "errorf:1:note:Errorf:a.go:SRCROOT:12:6-12:26", "errorf:1:note:Errorf:a.go:SRCROOT:13:9-13:28"),
+		},
+		"unregistered": {TD("a.go"), nil, []*astquery.Rule{dontpanic}, "",
+			S("dontpanic:error"),
+			S("dontpanic:0:error:don't panic:a.go::10:3-10:8"),
+		},
+		"noresult": {TD("a.go"), []*astquery.Rule{unused}, []*astquery.Rule{unused}, "",
+			S("unused:warning"),
+			nil,
+		},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			fset := token.NewFileSet()
+			files := parse(t, fset, tt.path)
+			e := astquery.New(fset, files, nil)
+			findings, err := e.CheckRules(tt.checked)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			r := astquery.NewSARIFReporter(tt.registered)
+			r.BaseDir = tt.baseDir
+			for _, f := range findings {
+				r.Report(fset, f)
+			}
+
+			var buf bytes.Buffer
+			if err := r.Write(&buf); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			var log sarif
+			if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if log.Version != "2.1.0" || len(log.Runs) != 1 {
+				t.Fatalf("unexpected log: %s", buf.String())
+			}
+
+			var gotRules []string
+			for _, rule := range log.Runs[0].Tool.Driver.Rules {
+				gotRules = append(gotRules, rule.ID+":"+rule.DefaultConfiguration.Level)
+			}
+			if diff := cmp.Diff(tt.wantRules, gotRules); diff != "" {
+				t.Error(diff)
+			}
+
+			var got []string
+			for _, result := range log.Runs[0].Results {
+				for _, loc := range result.Locations {
+					l := loc.PhysicalLocation
+					got = append(got, fmt.Sprintf("%s:%d:%s:%s:%s:%s:%d:%d-%d:%d",
+						result.RuleID, result.RuleIndex, result.Level, result.Message.Text,
+						l.ArtifactLocation.URI, l.ArtifactLocation.URIBaseID,
+						l.Region.StartLine, l.Region.StartColumn, l.Region.EndLine, l.Region.EndColumn))
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestSARIFReporter_Column(t *testing.T) {
+	t.Parallel()
+
+	src := "package a\n\nfunc f() {\n\t_ = \"日本語\"; panic(\"ぱにっく\")\n}\n\n//line b.go:10\nfunc g() { panic(\"ぱにっく\") }\n"
+	path := filepath.Join(t.TempDir(), "a.go")
+	if err := ioutil.WriteFile(path, []byte(src), 0o666); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, 0)
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	e := astquery.New(fset, []*ast.File{f}, nil)
+	rule := &astquery.Rule{ID: "dontpanic", XPath: "//*[@type='CallExpr'][Fun/@Name='panic']", Message: "don't panic"}
+	findings, err := e.CheckRules([]*astquery.Rule{rule})
+	if err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	r := astquery.NewSARIFReporter([]*astquery.Rule{rule})
+	for _, f := range findings {
+		r.Report(fset, f)
+	}
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var log struct {
+		Runs []struct {
+			ColumnKind string
+			Results    []struct {
+				Locations []struct {
+					PhysicalLocation struct {
+						Region struct{ StartLine, StartColumn, EndLine, EndColumn int }
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+
+	var got []string
+	for _, run := range log.Runs {
+		got = append(got, run.ColumnKind)
+		for _, result := range run.Results {
+			for _, loc := range result.Locations {
+				rg := loc.PhysicalLocation.Region
+				got = append(got, fmt.Sprintf("%d:%d-%d:%d", rg.StartLine, rg.StartColumn, rg.EndLine, rg.EndColumn))
+			}
+		}
+	}
+
+	// byte-based columns are 4:19-4:40 and columns are unknown in b.go
+	want := []string{"unicodeCodePoints", "4:13-4:26", "10:0-10:0"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
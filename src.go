@@ -0,0 +1,82 @@
+package astquery
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"sync"
+)
+
+// srcCache caches source code representations of nodes.
+// A node which cannot be formatted is cached as an empty string.
+type srcCache struct {
+	mu    sync.Mutex
+	m     map[ast.Node]string
+	files map[*token.File][]byte // nil if the file cannot be read
+}
+
+func newSrcCache() *srcCache {
+	return &srcCache{
+		m:     make(map[ast.Node]string),
+		files: make(map[*token.File][]byte),
+	}
+}
+
+func (c *srcCache) src(fset *token.FileSet, n ast.Node) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if src, ok := c.m[n]; ok {
+		return src
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, n); err != nil {
+		// a broken node cannot be formatted
+		c.m[n] = ""
+		return ""
+	}
+	src := buf.String()
+	c.m[n] = src
+
+	return src
+}
+
+// canFormat reports whether the node can be formatted by format.Node.
+func canFormat(n ast.Node) bool {
+	switch n.(type) {
+	case ast.Expr, ast.Stmt, ast.Decl, ast.Spec, *ast.File:
+		return true
+	}
+	return false
+}
+
+// text returns the source code of the node which is sliced from its file.
+// If the file cannot be read or it has been changed after parsing,
+// it returns the source code which is formatted by go/format.
+func (c *srcCache) text(fset *token.FileSet, n ast.Node) string {
+	tf := fset.File(n.Pos())
+	if tf == nil {
+		return c.src(fset, n)
+	}
+
+	c.mu.Lock()
+	src, ok := c.files[tf]
+	if !ok {
+		data, err := ioutil.ReadFile(tf.Name())
+		if err == nil && len(data) == tf.Size() {
+			src = data
+		}
+		c.files[tf] = src
+	}
+	c.mu.Unlock()
+
+	start, end := int(n.Pos())-tf.Base(), int(n.End())-tf.Base()
+	if src == nil || end < start || end > len(src) {
+		return c.src(fset, n)
+	}
+
+	return string(src[start:end])
+}
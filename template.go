@@ -0,0 +1,226 @@
+package astquery
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Template is a Go code template which replaces a node.
+// A placeholder {{.Path}} in the template is replaced with a value which is referred by the path from the node.
+// A path consists of field names of nodes with optional indexes and the last element can be an attribute name.
+// If the path refers a node, the placeholder is replaced with the node's source code in its file
+// which keeps comments and formatting.
+// If the file cannot be read, the node's @src is used instead.
+// {{.}} is replaced with the source code of the node itself.
+//
+// Example:
+//	errors.New({{.Args[0].src}})
+//	{{.Fun.Name}}({{.Args[1]}})
+type Template struct {
+	src   string
+	parts []templatePart
+}
+
+// templatePart is a text or a placeholder of a template.
+type templatePart struct {
+	text string
+	path []pathElem // nil for a text
+}
+
+// pathElem is an element of a placeholder's path such as Args[0].
+type pathElem struct {
+	name  string
+	index int // -1 if no index
+}
+
+func (e pathElem) String() string {
+	if e.index < 0 {
+		return "." + e.name
+	}
+	return fmt.Sprintf(".%s[%d]", e.name, e.index)
+}
+
+// ParseTemplate parses a template.
+func ParseTemplate(s string) (*Template, error) {
+	t := &Template{src: s}
+	for s != "" {
+		start := strings.Index(s, "{{")
+		if start < 0 {
+			t.parts = append(t.parts, templatePart{text: s})
+			break
+		}
+
+		if start > 0 {
+			t.parts = append(t.parts, templatePart{text: s[:start]})
+		}
+
+		end := strings.Index(s[start:], "}}")
+		if end < 0 {
+			return nil, fmt.Errorf("template cannot parse: unclosed placeholder %q", s[start:])
+		}
+
+		path, err := parsePath(strings.TrimSpace(s[start+2 : start+end]))
+		if err != nil {
+			return nil, fmt.Errorf("template cannot parse: %w", err)
+		}
+		t.parts = append(t.parts, templatePart{path: path})
+		s = s[start+end+2:]
+	}
+	return t, nil
+}
+
+// MustParseTemplate is like ParseTemplate but panics if the template cannot be parsed.
+func MustParseTemplate(s string) *Template {
+	t, err := ParseTemplate(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// String returns the source of the template.
+func (t *Template) String() string {
+	return t.src
+}
+
+// parsePath parses a path such as .Args[0].src.
+// The path "." returns an empty non-nil path.
+func parsePath(s string) ([]pathElem, error) {
+	if s == "." {
+		return []pathElem{}, nil
+	}
+
+	if !strings.HasPrefix(s, ".") {
+		return nil, fmt.Errorf("invalid path %q: must start with '.'", s)
+	}
+
+	var path []pathElem
+	for _, elem := range strings.Split(s[1:], ".") {
+		e := pathElem{name: elem, index: -1}
+		if i := strings.Index(elem, "["); i >= 0 {
+			if !strings.HasSuffix(elem, "]") {
+				return nil, fmt.Errorf("invalid path %q: unclosed index", s)
+			}
+			index, err := strconv.Atoi(elem[i+1 : len(elem)-1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path %q: invalid index %q", s, elem[i+1:len(elem)-1])
+			}
+			e.name, e.index = elem[:i], index
+		}
+
+		if e.name == "" || !isNameStart(rune(e.name[0])) {
+			return nil, fmt.Errorf("invalid path %q: invalid name %q", s, e.name)
+		}
+		for i := 1; i < len(e.name); i++ {
+			if !isNameChar(rune(e.name[i])) {
+				return nil, fmt.Errorf("invalid path %q: invalid name %q", s, e.name)
+			}
+		}
+
+		path = append(path, e)
+	}
+
+	return path, nil
+}
+
+// execute returns the result of the template for the node.
+func (t *Template) execute(n *NodeNavigator, node ast.Node) (string, error) {
+	var sb strings.Builder
+	for _, p := range t.parts {
+		if p.path == nil {
+			sb.WriteString(p.text)
+			continue
+		}
+
+		v, err := n.pathValue(node, p.path)
+		if err != nil {
+			return "", fmt.Errorf("template cannot execute: %w", err)
+		}
+		sb.WriteString(v)
+	}
+	return sb.String(), nil
+}
+
+// pathValue returns the value which is referred by the path from the node.
+func (n *NodeNavigator) pathValue(node ast.Node, path []pathElem) (string, error) {
+	for i, e := range path {
+		if child, ok := fieldNode(node, e); ok {
+			if child == nil {
+				return "", fmt.Errorf("%s: no such node", pathString(path[:i+1]))
+			}
+			node = child
+			continue
+		}
+
+		// the last element can be an attribute
+		if i != len(path)-1 || e.index >= 0 {
+			return "", fmt.Errorf("%s: no such node", pathString(path[:i+1]))
+		}
+
+		for _, a := range n.attributes(node) {
+			if a.call == nil && a.name == e.name {
+				return a.value(), nil
+			}
+		}
+
+		return "", fmt.Errorf("%s: no such attribute", pathString(path))
+	}
+
+	if !canFormat(node) {
+		return "", errors.New(pathString(path) + ": cannot be formatted")
+	}
+
+	return n.cache.text(n.fset, node), nil
+}
+
+// fieldNode returns the node which is referred by the path element.
+// If the node does not have the field or the field is not a node, the second result is false.
+// If the field is a nil node or the index is out of range, the first result is nil.
+func fieldNode(node ast.Node, e pathElem) (ast.Node, bool) {
+	rv := reflect.Indirect(reflect.ValueOf(node))
+	if rv.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	f := rv.FieldByName(e.name)
+	if !f.IsValid() {
+		return nil, false
+	}
+
+	if e.index >= 0 {
+		if f.Kind() != reflect.Slice || !f.Type().Elem().Implements(nodeType) {
+			return nil, false
+		}
+		if e.index >= f.Len() {
+			return nil, true
+		}
+		f = f.Index(e.index)
+	}
+
+	if !f.Type().Implements(nodeType) {
+		return nil, false
+	}
+
+	if f.IsNil() {
+		return nil, true
+	}
+
+	return f.Interface().(ast.Node), true
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+func pathString(path []pathElem) string {
+	if len(path) == 0 {
+		return "."
+	}
+	var sb strings.Builder
+	for _, e := range path {
+		sb.WriteString(e.String())
+	}
+	return sb.String()
+}
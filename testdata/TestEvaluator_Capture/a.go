@@ -0,0 +1,14 @@
+-- a.go --
+package a
+
+import "strings"
+
+type T struct{}
+
+func (T) M(n int) {}
+
+func f(t T, s string) {
+	t.M(1)
+	strings.ToUpper(s)
+	println(s)
+}
@@ -0,0 +1,6 @@
+-- a.go --
+package a
+
+func f() {
+	println("hello")
+}
@@ -0,0 +1,15 @@
+-- a.go --
+package a
+
+import (
+	"errors"
+	"fmt"
+)
+
+func f() error {
+	_ = fmt.Errorf("static")
+	_ = fmt.Errorf("%d", 10)
+	return fmt.Errorf("error")
+}
+
+var _ = errors.New
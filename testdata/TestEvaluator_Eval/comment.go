@@ -0,0 +1,11 @@
+-- a.go --
+package a
+
+// F does nothing.
+func F() {}
+
+// does nothing.
+func G() {
+	// TODO: implement
+	println() // print newline
+}
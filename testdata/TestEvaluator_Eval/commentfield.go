@@ -0,0 +1,6 @@
+-- a.go --
+package a
+
+type T struct{}
+
+func /* receiver */ (t T) M() {}
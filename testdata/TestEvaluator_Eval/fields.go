@@ -0,0 +1,15 @@
+-- a.go --
+package a
+
+func f(c chan<- int, a, b int) {
+	x := a == b
+	_ = x
+	if c != nil {
+		return
+	}
+}
+
+func g(xs ...int) {
+	g(xs...)
+	g(1)
+}
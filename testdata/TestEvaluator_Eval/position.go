@@ -0,0 +1,12 @@
+-- a.go --
+package a
+
+func f() {
+	print()
+}
+
+func g() {
+	print()
+	print()
+	print()
+}
@@ -0,0 +1,14 @@
+-- a.go --
+package a
+
+import "errors"
+
+type T struct{}
+
+func (T) Close() error { return errors.New("error") }
+
+func f() {
+	var t T
+	t.Close()
+	println()
+}
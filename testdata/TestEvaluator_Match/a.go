@@ -0,0 +1,21 @@
+-- a.go --
+package a
+
+import "fmt"
+
+func f(err error, n int) error {
+	if err != nil {
+		return fmt.Errorf("f: %w", err)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Errorf("n")
+	fmt.Errorf("$x") // $x
+	fmt.Println(n, n)
+	fmt.Println(n, 1)
+	n = n + 1
+	return fmt.Errorf("%d %d", n, 1)
+}
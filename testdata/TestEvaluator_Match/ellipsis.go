@@ -0,0 +1,13 @@
+-- a.go --
+package a
+
+func f(xs ...int) {}
+
+func g(s []int, x int) {
+	f(x)
+	f(s...)
+	s = append(s, s...)
+
+	type A = int
+	type B int
+}
@@ -0,0 +1,9 @@
+-- a.go --
+package a
+
+import "fmt"
+
+// f is a function.
+func f() {
+	fmt.Println("hello")
+}
@@ -0,0 +1,20 @@
+-- a.go --
+package a
+
+func f(x int) int {
+	return g(g(x))
+}
+
+func g(x int) int { return x }
+
+func g2(x int) int { return x }
+-- a.go.golden --
+package a
+
+func f(x int) int {
+	return g(g2(x))
+}
+
+func g(x int) int { return x }
+
+func g2(x int) int { return x }
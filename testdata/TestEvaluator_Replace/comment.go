@@ -0,0 +1,22 @@
+-- a.go --
+package a
+
+func f(x int) int {
+	return g(func() int {
+		// keep this comment
+		return x
+	}())
+}
+
+func g(x int) int { return x }
+-- a.go.golden --
+package a
+
+func f(x int) int {
+	return h(func() int {
+		// keep this comment
+		return x
+	}())
+}
+
+func g(x int) int { return x }
@@ -0,0 +1,30 @@
+-- a.go --
+package a
+
+import (
+	"errors"
+	"fmt"
+)
+
+func f() error {
+	_ = fmt.Errorf("static")
+	_ = fmt.Errorf("%d", 10)
+	return fmt.Errorf("error")
+}
+
+var _ = errors.New
+-- a.go.golden --
+package a
+
+import (
+	"errors"
+	"fmt"
+)
+
+func f() error {
+	_ = errors.New("static")
+	_ = fmt.Errorf("%d", 10)
+	return errors.New("error")
+}
+
+var _ = errors.New
@@ -0,0 +1,20 @@
+-- a.go --
+package a
+
+func f(x int) int { return g(x) }
+
+func g(x int) int { return x }
+-- b.go --
+package a
+
+func k(x int) int { return g(x) + g(x) }
+-- a.go.golden --
+package a
+
+func f(x int) int { return h(x) }
+
+func g(x int) int { return x }
+-- b.go.golden --
+package a
+
+func k(x int) int { return h(x) + h(x) }
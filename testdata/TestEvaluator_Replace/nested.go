@@ -0,0 +1,16 @@
+-- a.go --
+package a
+
+func f(x int) int {
+	return g(g(x))
+}
+
+func g(x int) int { return x }
+-- a.go.golden --
+package a
+
+func f(x int) int {
+	return h(g(x))
+}
+
+func g(x int) int { return x }
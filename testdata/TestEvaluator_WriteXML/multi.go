@@ -0,0 +1,8 @@
+-- a.go --
+package a
+
+func f() {}
+-- b.go --
+package a
+
+func g() {}
@@ -0,0 +1,8 @@
+-- 0_a.go --
+package a
+
+func f() {}
+-- a b.go --
+package a
+
+func g() {}
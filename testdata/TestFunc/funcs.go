@@ -0,0 +1,22 @@
+-- a.go --
+package a
+
+import "io"
+
+type R struct{}
+
+func (R) Read(p []byte) (int, error) { return 0, io.EOF }
+
+type W struct{}
+
+func Exported() {}
+
+func unexported() {
+	var r R
+	var w W
+	_, _ = r, w
+}
+-- a_test.go --
+package a
+
+func TestA() {}
@@ -0,0 +1,4 @@
+-- a.go --
+package a
+
+func F() {}
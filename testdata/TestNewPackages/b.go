@@ -0,0 +1,8 @@
+-- b.go --
+package b
+
+func init() {}
+-- b2.go --
+package b
+
+var V = 1
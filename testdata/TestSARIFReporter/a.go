@@ -0,0 +1,17 @@
+-- a.go --
+package a
+
+import (
+	"errors"
+	"fmt"
+)
+
+func f() error {
+	if false {
+		panic("panic!!")
+	}
+	_ = fmt.Errorf("%d", 10)
+	return fmt.Errorf("error")
+}
+
+var _ = errors.New
@@ -0,0 +1,133 @@
+package astquery
+
+import (
+	"go/ast"
+	"go/types"
+)
+
+// typesAttributes returns attributes of the node which are derived from type information.
+// Their values are computed lazily by typesValue.
+func (n *NodeNavigator) typesAttributes(node ast.Node) []attr {
+	pkg, info := n.typesOf(node)
+
+	if _, ok := node.(*ast.File); ok {
+		if pkg != nil {
+			return []attr{{parent: node, name: "pkgpath", nav: n}}
+		}
+		return nil
+	}
+
+	if info == nil {
+		return nil
+	}
+
+	var attrs []attr
+	if expr, ok := node.(ast.Expr); ok && info.TypeOf(expr) != nil {
+		attrs = append(attrs, attr{parent: node, name: "typeof", nav: n})
+	}
+
+	obj := objectOf(info, node)
+	if obj == nil {
+		return attrs
+	}
+
+	attrs = append(attrs,
+		attr{parent: node, name: "obj", nav: n},
+		attr{parent: node, name: "objkind", nav: n},
+	)
+
+	if objectPkgPath(obj) != "" {
+		attrs = append(attrs, attr{parent: node, name: "pkgpath", nav: n})
+	}
+
+	return attrs
+}
+
+func (n *NodeNavigator) typesValue(node ast.Node, name string) string {
+	pkg, info := n.typesOf(node)
+
+	if _, ok := node.(*ast.File); ok {
+		return pkg.Path()
+	}
+
+	if name == "typeof" {
+		return info.TypeOf(node.(ast.Expr)).String()
+	}
+
+	obj := objectOf(info, node)
+	switch name {
+	case "obj":
+		return objectPath(obj)
+	case "objkind":
+		return objectKind(obj)
+	}
+	return objectPkgPath(obj)
+}
+
+func objectOf(info *types.Info, n ast.Node) types.Object {
+	switch n := n.(type) {
+	case *ast.Ident:
+		return info.ObjectOf(n)
+	case *ast.SelectorExpr:
+		return info.ObjectOf(n.Sel)
+	case *ast.FuncDecl:
+		return info.ObjectOf(n.Name)
+	case *ast.TypeSpec:
+		return info.ObjectOf(n.Name)
+	case *ast.ImportSpec:
+		if n.Name != nil {
+			return info.ObjectOf(n.Name)
+		}
+		return info.Implicits[n]
+	}
+	return nil
+}
+
+// objectPath returns a qualified path of the object such as "(*os.File).Close".
+func objectPath(obj types.Object) string {
+	switch obj := obj.(type) {
+	case *types.Func:
+		return obj.FullName()
+	case *types.PkgName:
+		return obj.Imported().Path()
+	}
+
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
+
+func objectKind(obj types.Object) string {
+	switch obj.(type) {
+	case *types.Func:
+		return "func"
+	case *types.Var:
+		return "var"
+	case *types.Const:
+		return "const"
+	case *types.TypeName:
+		return "typename"
+	case *types.PkgName:
+		return "pkgname"
+	case *types.Label:
+		return "label"
+	case *types.Builtin:
+		return "builtin"
+	case *types.Nil:
+		return "nil"
+	}
+	return ""
+}
+
+// objectPkgPath returns the path of the package which the object belongs to.
+// For a package name, it returns the path of the imported package.
+func objectPkgPath(obj types.Object) string {
+	if pkgName, ok := obj.(*types.PkgName); ok {
+		return pkgName.Imported().Path()
+	}
+	if obj.Pkg() == nil {
+		return ""
+	}
+	return obj.Pkg().Path()
+}
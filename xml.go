@@ -0,0 +1,132 @@
+package astquery
+
+import (
+	"encoding/xml"
+	"fmt"
+	"go/ast"
+	"go/token"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xpath"
+)
+
+// xmlRoot is the name of the element which wraps multiple elements.
+const xmlRoot = "astquery"
+
+// WriteXML writes the tree of the files as XML.
+// See Evaluator.WriteXML.
+func WriteXML(w io.Writer, fset *token.FileSet, files []*ast.File, opts ...Option) error {
+	return New(fset, files, nil, opts...).WriteXML(w)
+}
+
+// WriteXML writes the tree under the nodes as XML which has the same elements and attributes as the tree for XPath.
+// If no node is given, it writes the whole tree.
+// Because XML must have a single root element, multiple elements such as files are wrapped by an "astquery" element.
+// For example, an XPath expression //*[@type='CallExpr'] for the tree corresponds to /astquery//*[@type='CallExpr'] for the XML.
+// Characters which cannot be used in XML names are replaced with '_' such as "_0_a.go" for a file 0_a.go.
+func (e *Evaluator) WriteXML(w io.Writer, ns ...ast.Node) error {
+	var navs []*NodeNavigator
+	if len(ns) == 0 {
+		n := e.n.Copy().(*NodeNavigator)
+		n.MoveToRoot()
+		for ok := n.MoveToChild(); ok; ok = n.MoveToNext() {
+			navs = append(navs, n.Copy().(*NodeNavigator))
+		}
+	}
+
+	for _, node := range ns {
+		n := e.n.Copy().(*NodeNavigator)
+		if !n.moveToNode(node) {
+			return fmt.Errorf("node %T is not in the tree", node)
+		}
+		if n.NodeType() == xpath.RootNode {
+			for ok := n.MoveToChild(); ok; ok = n.MoveToNext() {
+				navs = append(navs, n.Copy().(*NodeNavigator))
+			}
+			continue
+		}
+		navs = append(navs, n)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	wrap := len(navs) != 1
+	root := xml.StartElement{Name: xml.Name{Local: xmlRoot}}
+	if wrap {
+		if err := enc.EncodeToken(root); err != nil {
+			return err
+		}
+	}
+
+	for _, n := range navs {
+		if err := encodeXML(enc, n); err != nil {
+			return err
+		}
+	}
+
+	if wrap {
+		if err := enc.EncodeToken(root.End()); err != nil {
+			return err
+		}
+	}
+
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func encodeXML(enc *xml.Encoder, n *NodeNavigator) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(n.LocalName())}}
+	attrs := n.Copy().(*NodeNavigator)
+	for attrs.MoveToNextAttribute() {
+		start.Attr = append(start.Attr, xml.Attr{
+			Name:  xml.Name{Local: attrs.LocalName()},
+			Value: attrs.Value(),
+		})
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	child := n.Copy().(*NodeNavigator)
+	for ok := child.MoveToChild(); ok; ok = child.MoveToNext() {
+		if err := encodeXML(enc, child.Copy().(*NodeNavigator)); err != nil {
+			return err
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName converts the name of an element to a valid XML name.
+func xmlName(name string) string {
+	var sb strings.Builder
+	for i, c := range name {
+		switch {
+		case i == 0 && !isNameStart(c):
+			sb.WriteByte('_')
+			if isNameChar(c) {
+				sb.WriteRune(c)
+			}
+		case isNameChar(c):
+			sb.WriteRune(c)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
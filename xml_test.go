@@ -0,0 +1,127 @@
+package astquery_test
+
+import (
+	"bytes"
+	"encoding/xml"
+	"go/ast"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/gostaticanalysis/astquery"
+)
+
+func TestEvaluator_WriteXML(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_WriteXML", f) }
+	cases := map[string]struct {
+		path    string
+		xpath   string
+		golden  string
+		wantErr bool
+	}{
+		"call":     {TD("a.go"), "//*[@type='CallExpr']", TD("call.golden"), false},
+		"notfound": {TD("a.go"), "", "", true},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			e := newEvaluator(t, tt.path)
+
+			var node ast.Node = &ast.Ident{Name: "notfound"}
+			if tt.xpath != "" {
+				var err error
+				node, err = e.SelectOne(tt.xpath)
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+			}
+
+			var buf bytes.Buffer
+			err := e.WriteXML(&buf, node)
+			switch {
+			case tt.wantErr && err == nil:
+				t.Fatal("expected error did not occur")
+			case !tt.wantErr && err != nil:
+				t.Fatal("unexpected error:", err)
+			case tt.wantErr:
+				return
+			}
+
+			want, err := ioutil.ReadFile(tt.golden)
+			if err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			if diff := cmp.Diff(string(want), trimNewFields(buf.String())); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestWriteXML(t *testing.T) {
+	t.Parallel()
+
+	TD := func(f string) string { return filepath.Join("testdata", "TestEvaluator_WriteXML", f) }
+	cases := map[string]struct {
+		path  string
+		root  string
+		files []string
+	}{
+		"single": {TD("a.go"), "a.go", nil},
+		"multi":  {TD("multi.go"), "astquery", []string{"a.go", "b.go"}},
+		"names":  {TD("names.go"), "astquery", []string{"_0_a.go", "a_b.go"}},
+	}
+
+	for n, tt := range cases {
+		tt := tt
+		t.Run(n, func(t *testing.T) {
+			t.Parallel()
+			fset := token.NewFileSet()
+			files := parse(t, fset, tt.path)
+
+			var buf bytes.Buffer
+			if err := astquery.WriteXML(&buf, fset, files); err != nil {
+				t.Fatal("unexpected error:", err)
+			}
+
+			// the output must be well-formed
+			var names []string
+			var depth int
+			dec := xml.NewDecoder(&buf)
+			for {
+				tok, err := dec.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal("unexpected error:", err)
+				}
+
+				switch tok := tok.(type) {
+				case xml.StartElement:
+					if depth <= 1 {
+						names = append(names, tok.Name.Local)
+					}
+					depth++
+				case xml.EndElement:
+					depth--
+				}
+			}
+
+			if names[0] != tt.root {
+				t.Errorf("root element want %s got %s", tt.root, names[0])
+			}
+			if diff := cmp.Diff(tt.files, names[1:]); tt.files != nil && diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}